@@ -1,17 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // profile time zones must resolve on hosts without zoneinfo
 
-	"github.com/alexedwards/argon2id"
+	"github.com/jinxinyu/go_backend/internal/audit"
 	"github.com/jinxinyu/go_backend/internal/auth"
 	"github.com/jinxinyu/go_backend/internal/config"
+	"github.com/jinxinyu/go_backend/internal/email"
+	"github.com/jinxinyu/go_backend/internal/oidc"
+	"github.com/jinxinyu/go_backend/internal/password"
 	"github.com/jinxinyu/go_backend/internal/router"
 	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/textstats"
 	"github.com/jinxinyu/go_backend/internal/utils"
+	"github.com/jinxinyu/go_backend/internal/writing"
 )
 
 func main() {
+	grantAdmin := flag.String("grant-admin", "", "grant the admin role to the user with this email and exit")
+	verifyAudit := flag.Bool("verify-audit", false, "verify the hash chain of the audit log and exit")
+	flag.Parse()
+
 	// load config
 	cfg, err := config.LoadConfig(".")
 	if err != nil {
@@ -23,22 +38,157 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// initialize token revocation store
+	var revocations storage.RevocationStore
+	if cfg.RevocationStore == "memory" {
+		revocations = storage.NewMemoryRevocationStore()
+	} else {
+		revocations = storage.NewRevocationRepository(db)
+	}
+	go storage.RunRevocationPruner(context.Background(), revocations, time.Duration(cfg.RevocationPruneIntervalMinutes)*time.Minute)
+
+	// initialize login attempt store
+	var loginAttempts storage.LoginAttemptStore
+	if cfg.LoginAttemptStore == "memory" {
+		loginAttempts = storage.NewMemoryLoginAttemptStore()
+	} else {
+		loginAttempts = storage.NewLoginAttemptRepository(db)
+	}
+
 	// initialize hash utils
-	hashutils := utils.NewHashedPassword(argon2id.DefaultParams)
-	tokenmaker, err := utils.NewTokenGenerator(cfg)
+	argon2Params, err := utils.NewArgon2Params(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize password hashing: %v", err)
+	}
+	log.Printf("argon2id 参数: memory=%dKiB, iterations=%d, parallelism=%d", argon2Params.Memory, argon2Params.Iterations, argon2Params.Parallelism)
+	hashutils := utils.NewBoundedHashedPassword(
+		utils.NewHashedPassword(argon2Params),
+		cfg.PasswordHashWorkers,
+		time.Duration(cfg.PasswordHashQueueTimeoutMS)*time.Millisecond,
+	)
+	tokenmaker, err := utils.NewTokenGenerator(cfg, revocations)
 	if err != nil {
 		log.Fatalf("Failed to initialize token maker: %v", err)
 	}
 
+	passwordPolicy, err := password.NewPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize password policy: %v", err)
+	}
+
+	linkSigner, err := utils.NewLinkSigner(cfg.LinkSigningSecret)
+	if err != nil {
+		log.Fatalf("Failed to initialize link signer: %v", err)
+	}
+
+	// initialize email
+	emailSender, err := email.NewSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize email sender: %v", err)
+	}
+	emailService := email.NewService(emailSender)
+
 	//initialize repo
 	userRepo := storage.NewUserRepository(db)
+	refreshTokenRepo := storage.NewRefreshTokenRepository(db)
+	actionTokenRepo := storage.NewActionTokenRepository(db)
+	recoveryCodeRepo := storage.NewRecoveryCodeRepository(db)
+	identityRepo := storage.NewIdentityRepository(db)
+	personalTokenRepo := storage.NewPersonalAccessTokenRepository(db)
+	sessionRepo := storage.NewSessionRepository(db)
+	roleRepo := storage.NewRoleRepository(db)
+	writeLogRepo := storage.NewWriteLogRepository(db)
+	auditRepo := storage.NewAuditRepository(db)
+	invitationRepo := storage.NewInvitationRepository(db)
+
+	auditRecorder := audit.NewRecorder(auditRepo)
+	if *verifyAudit {
+		runAuditVerification(auditRecorder)
+		return
+	}
 
 	//initialize service
-	authService := auth.NewService(userRepo, tokenmaker, hashutils)
+	authService := auth.NewService(cfg, auth.Dependencies{
+		UserRepo:          userRepo,
+		RefreshTokenRepo:  refreshTokenRepo,
+		Revocations:       revocations,
+		ActionTokenRepo:   actionTokenRepo,
+		RecoveryCodeRepo:  recoveryCodeRepo,
+		LoginAttempts:     loginAttempts,
+		IdentityRepo:      identityRepo,
+		PersonalTokenRepo: personalTokenRepo,
+		SessionRepo:       sessionRepo,
+		RoleRepo:          roleRepo,
+		WriteLogRepo:      writeLogRepo,
+		InvitationRepo:    invitationRepo,
+		TokenMaker:        tokenmaker,
+		HashPassword:      hashutils,
+		Signer:            linkSigner,
+		PasswordPolicy:    passwordPolicy,
+		Email:             emailService,
+		OIDC:              oidc.NewRegistry(cfg.OIDCProviders, nil),
+		Audit:             auditRecorder,
+	})
+
+	writingService := writing.NewService(writeLogRepo, userRepo, textstats.NewCounter(cfg))
+
+	// seed roles and bootstrap admins
+	if err := seedAdmins(authService, cfg.AdminEmails, *grantAdmin); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+	if *grantAdmin != "" {
+		log.Printf("已授予管理员角色: %s", *grantAdmin)
+		return
+	}
+
+	go auth.RunAccountPurger(context.Background(), authService, time.Duration(cfg.AccountPurgeIntervalMinutes)*time.Minute)
 
 	//initialize router
-	router := router.SetupRouter(authService)
+	router := router.SetupRouter(authService, writingService, auditRecorder, tokenmaker)
 
 	//start server
 	router.Run(":" + cfg.ServerPort)
 }
+
+// seedAdmins creates the built-in roles and grants the admin role to the
+// configured emails, plus the one given on the command line
+func seedAdmins(authService *auth.Service, configured string, fromFlag string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := authService.SeedRoles(ctx); err != nil {
+		return err
+	}
+	if fromFlag != "" {
+		return authService.GrantRoleByEmail(ctx, strings.TrimSpace(fromFlag), utils.RoleAdmin)
+	}
+	for _, email := range strings.Split(configured, ",") {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		err := authService.GrantRoleByEmail(ctx, email, utils.RoleAdmin)
+		if errors.Is(err, auth.ErrUserNotFound) {
+			log.Printf("管理员账户尚未注册, 稍后重启生效: %s", email)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAuditVerification checks the whole audit chain and exits non-zero when
+// an event was changed or removed
+func runAuditVerification(recorder *audit.Recorder) {
+	checked, err := recorder.Verify(context.Background())
+	if err != nil {
+		var chainErr *audit.ChainError
+		if errors.As(err, &chainErr) {
+			log.Fatalf("审计日志校验失败, 已校验 %d 条: %v", checked, chainErr)
+		}
+		log.Fatalf("Failed to verify audit log: %v", err)
+	}
+	log.Printf("审计日志校验通过, 共 %d 条", checked)
+}
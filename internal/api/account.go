@@ -0,0 +1,32 @@
+package api
+
+import "time"
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"` //TOTP or recovery code, required when two-factor authentication is enabled
+}
+
+type DeleteAccountResponse struct {
+	PurgeAt time.Time `json:"purgeAt"` //the account can be restored until then
+}
+
+type RestoreAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// AccountExport is the profile.json file of an account archive
+type AccountExport struct {
+	ExportedAt      time.Time        `json:"exportedAt"`
+	User            UserResponse     `json:"user"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	EmailVerifiedAt *time.Time       `json:"emailVerifiedAt,omitempty"`
+	Identities      []IdentityExport `json:"identities"`
+}
+
+type IdentityExport struct {
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
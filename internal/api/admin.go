@@ -0,0 +1,39 @@
+package api
+
+import "time"
+
+type ListUsersQuery struct {
+	Search   string `form:"q"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"pageSize" binding:"omitempty,min=1,max=100"`
+}
+
+type AdminUserResponse struct {
+	UserResponse
+	CreatedAt time.Time  `json:"createdAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"` //set while the account waits to be purged
+	InvitedBy string     `json:"invitedBy,omitempty"`
+	// InviteQuota is how many people the user may invite
+	InviteQuota int `json:"inviteQuota"`
+}
+
+type UserListResponse struct {
+	Users    []AdminUserResponse `json:"users"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"pageSize"`
+}
+
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required"` //an empty list removes every role
+}
+
+type SetInviteQuotaRequest struct {
+	Quota *int `json:"quota" binding:"required,min=0,max=10000"`
+}
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditQuery struct {
+	Type      string     `form:"type"`
+	ActorID   string     `form:"actorId" binding:"omitempty,uuid"`
+	SubjectID string     `form:"subjectId" binding:"omitempty,uuid"`
+	From      *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page      int        `form:"page" binding:"omitempty,min=1"`
+	PageSize  int        `form:"pageSize" binding:"omitempty,min=1,max=200"`
+}
+
+type AuditEventResponse struct {
+	ID        uint64          `json:"id"`
+	CreatedAt time.Time       `json:"createdAt"`
+	EventType string          `json:"eventType"`
+	ActorID   string          `json:"actorId,omitempty"`
+	SubjectID string          `json:"subjectId,omitempty"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"userAgent"`
+	Metadata  json.RawMessage `json:"metadata"`
+	Hash      string          `json:"hash"`
+}
+
+type AuditListResponse struct {
+	Events   []AuditEventResponse `json:"events"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"pageSize"`
+}
@@ -3,7 +3,9 @@ package api
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required,min=1"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"` //strength is checked against the password policy
+	// InviteCode is required while registration is invite only
+	InviteCode string `json:"inviteCode" binding:"omitempty,max=32"`
 }
 
 type LoginRequest struct {
@@ -12,12 +14,80 @@ type LoginRequest struct {
 }
 
 type UserResponse struct {
-	ID    string `json:"id"` //id is returned as string
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID               string   `json:"id"` //id is returned as string
+	Name             string   `json:"name"`
+	Email            string   `json:"email"`
+	EmailVerified    bool     `json:"emailVerified"`
+	TwoFactorEnabled bool     `json:"twoFactorEnabled"`
+	TimeZone         string   `json:"timeZone"`
+	PendingEmail     string   `json:"pendingEmail,omitempty"` //new address of an unconfirmed email change
+	Roles            []string `json:"roles,omitempty"`
 }
 
+// LoginResponse carries the tokens of a completed login. When the account has
+// two-factor authentication enabled, only TwoFactorRequired and ChallengeToken
+// are set and the login is finished through /auth/login/2fa.
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token             string        `json:"token,omitempty"`
+	RefreshToken      string        `json:"refreshToken,omitempty"`
+	User              *UserResponse `json:"user,omitempty"`
+	TwoFactorRequired bool          `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string        `json:"challengeToken,omitempty"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"` //TOTP code or recovery code
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"` //otpauth:// URI to render as QR code
+}
+
+type TwoFactorConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+	AllDevices   bool   `json:"allDevices"` //also revoke every other token of the user
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" binding:"required"`
 }
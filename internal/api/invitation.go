@@ -0,0 +1,43 @@
+package api
+
+import "time"
+
+type CreateInvitationRequest struct {
+	MaxUses       int `json:"maxUses" binding:"omitempty,min=1,max=1000"`      //defaults to 1
+	ExpiresInDays int `json:"expiresInDays" binding:"omitempty,min=1,max=365"` //0 means the configured default
+}
+
+type ListInvitationsQuery struct {
+	Page     int `form:"page" binding:"omitempty,min=1"`
+	PageSize int `form:"pageSize" binding:"omitempty,min=1,max=100"`
+}
+
+type InvitationResponse struct {
+	ID        string     `json:"id"`
+	Code      string     `json:"code"`
+	Link      string     `json:"link"` //registration page with the code filled in
+	InviterID string     `json:"inviterId,omitempty"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	AdminIssued bool `json:"adminIssued"` //does not count against the invite quota
+}
+
+// MyInvitationsResponse lists the invitations of the caller. Remaining is how
+// many more people they may invite, seats of revoked or expired invitations
+// that were never used are given back.
+type MyInvitationsResponse struct {
+	Invitations []InvitationResponse `json:"invitations"`
+	Quota       int                  `json:"quota"`
+	Remaining   int                  `json:"remaining"`
+}
+
+type InvitationListResponse struct {
+	Invitations []InvitationResponse `json:"invitations"`
+	Total       int64                `json:"total"`
+	Page        int                  `json:"page"`
+	PageSize    int                  `json:"pageSize"`
+}
@@ -0,0 +1,25 @@
+package api
+
+import "time"
+
+type CreatePersonalTokenRequest struct {
+	Name          string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"omitempty,min=1,max=3650"` //0 means the token never expires
+}
+
+type PersonalTokenResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Prefix     string     `json:"prefix"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// CreatePersonalTokenResponse carries the plain token, it is only shown this one time
+type CreatePersonalTokenResponse struct {
+	Token         string                `json:"token"`
+	PersonalToken PersonalTokenResponse `json:"personalToken"`
+}
@@ -0,0 +1,21 @@
+package api
+
+// UpdateProfileRequest changes the fields that are set and leaves the others untouched
+type UpdateProfileRequest struct {
+	Name     *string `json:"name" binding:"omitempty,min=1,max=255"`
+	TimeZone *string `json:"timeZone" binding:"omitempty,max=64"` //IANA name, e.g. "Asia/Shanghai"
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"` //strength is checked against the password policy
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
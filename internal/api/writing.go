@@ -0,0 +1,93 @@
+package api
+
+import "time"
+
+// Dates of writing logs are calendar days of the user, formatted 2006-01-02
+
+type CreateWriteLogRequest struct {
+	Date    string `json:"date" binding:"omitempty,datetime=2006-01-02"` //defaults to today in the time zone of the user
+	Content string `json:"content" binding:"required,max=200000"`
+}
+
+// UpdateWriteLogRequest changes the fields that are set
+type UpdateWriteLogRequest struct {
+	Date    *string `json:"date" binding:"omitempty,datetime=2006-01-02"`
+	Content *string `json:"content" binding:"omitempty,min=1,max=200000"`
+}
+
+type ListWriteLogsQuery struct {
+	From string `form:"from" binding:"omitempty,datetime=2006-01-02"` //defaults to 30 days before To
+	To   string `form:"to" binding:"omitempty,datetime=2006-01-02"`   //defaults to today
+}
+
+type WriteLogResponse struct {
+	ID         string        `json:"id"`
+	Date       string        `json:"date"`
+	WordsCount int           `json:"wordsCount"`
+	Stats      WriteLogStats `json:"stats"`
+	Content    string        `json:"content"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+}
+
+// WriteLogStats break WordsCount down, see package textstats
+type WriteLogStats struct {
+	HanCharacters int `json:"hanCharacters"`
+	KanaHangul    int `json:"kanaHangul"`
+	LatinWords    int `json:"latinWords"`
+	Numbers       int `json:"numbers"`
+	Punctuation   int `json:"punctuation"`
+	Paragraphs    int `json:"paragraphs"`
+	Characters    int `json:"characters"`
+}
+
+type WriteLogListResponse struct {
+	Logs []WriteLogResponse `json:"logs"`
+	From string             `json:"from"`
+	To   string             `json:"to"`
+}
+
+type StatsQuery struct {
+	Period string `form:"period" binding:"omitempty,oneof=day week month"` //defaults to day
+	From   string `form:"from" binding:"omitempty,datetime=2006-01-02"`    //defaults to 30 days, 12 weeks or 12 months before To
+	To     string `form:"to" binding:"omitempty,datetime=2006-01-02"`      //defaults to today
+}
+
+// StatsBucket holds the totals of one day, ISO week or month. Start is its
+// first day, the first and last bucket may be cut by the range.
+type StatsBucket struct {
+	Start         string `json:"start"`
+	Days          int    `json:"days"` //days of the bucket inside the range
+	ActiveDays    int    `json:"activeDays"`
+	Logs          int    `json:"logs"`
+	Words         int    `json:"words"`
+	HanCharacters int    `json:"hanCharacters"`
+	KanaHangul    int    `json:"kanaHangul"`
+	LatinWords    int    `json:"latinWords"`
+	// AverageWordsPerDay is Words over Days
+	AverageWordsPerDay float64 `json:"averageWordsPerDay"`
+}
+
+type StatsTotals struct {
+	Days               int     `json:"days"`
+	ActiveDays         int     `json:"activeDays"`
+	Logs               int     `json:"logs"`
+	Words              int     `json:"words"`
+	HanCharacters      int     `json:"hanCharacters"`
+	KanaHangul         int     `json:"kanaHangul"`
+	LatinWords         int     `json:"latinWords"`
+	AverageWordsPerDay float64 `json:"averageWordsPerDay"`
+	// AverageWordsPerBucket is Words over the number of buckets
+	AverageWordsPerBucket float64 `json:"averageWordsPerBucket"`
+}
+
+// StatsResponse aggregates the writing logs of the user. Every bucket of the
+// range is listed, the ones without logs with zero counts.
+type StatsResponse struct {
+	Period   string        `json:"period"`
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	TimeZone string        `json:"timeZone"`
+	Buckets  []StatsBucket `json:"buckets"`
+	Totals   StatsTotals   `json:"totals"`
+}
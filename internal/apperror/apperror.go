@@ -0,0 +1,152 @@
+// Package apperror defines the errors the service layer returns to clients.
+// Each error carries a kind, mapped to an HTTP status by the error middleware,
+// and a stable code clients can switch on.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kind classifies an error for the HTTP layer
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindTooManyRequests
+	KindUnavailable
+	KindBadGateway
+)
+
+var kindStatus = map[Kind]int{
+	KindInternal:        http.StatusInternalServerError,
+	KindInvalid:         http.StatusBadRequest,
+	KindUnauthorized:    http.StatusUnauthorized,
+	KindForbidden:       http.StatusForbidden,
+	KindNotFound:        http.StatusNotFound,
+	KindConflict:        http.StatusConflict,
+	KindTooManyRequests: http.StatusTooManyRequests,
+	KindUnavailable:     http.StatusServiceUnavailable,
+	KindBadGateway:      http.StatusBadGateway,
+}
+
+// Status returns the HTTP status code of the kind
+func (k Kind) Status() int {
+	if status, ok := kindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError describes why one field of a request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error meant to reach the client. Errors are compared by code, so
+// a sentinel still matches errors.Is after WithDetail or WithRetryAfter.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	// RetryAfter is sent as Retry-After header when set
+	RetryAfter time.Duration
+}
+
+func New(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func Invalid(code string, message string) *Error {
+	return New(KindInvalid, code, message)
+}
+
+func Unauthorized(code string, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func NotFound(code string, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func TooManyRequests(code string, message string) *Error {
+	return New(KindTooManyRequests, code, message)
+}
+
+func Unavailable(code string, message string) *Error {
+	return New(KindUnavailable, code, message)
+}
+
+// Generic errors shared by all handlers
+var (
+	ErrInternal     = New(KindInternal, "internal_error", "服务器内部错误，请稍后再试")
+	ErrUnauthorized = Unauthorized("unauthorized", "unauthorized")
+	ErrForbidden    = Forbidden("forbidden", "permission denied")
+	ErrNotFound     = NotFound("not_found", "not found")
+)
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches errors with the same code
+func (e *Error) Is(target error) bool {
+	var other *Error
+	if !errors.As(target, &other) {
+		return false
+	}
+	return other.Code == e.Code
+}
+
+// Status returns the HTTP status code of the error
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+// WithDetail returns a copy of the error with a more specific message
+func (e *Error) WithDetail(format string, args ...interface{}) *Error {
+	copied := *e
+	copied.Message = e.Message + ": " + fmt.Sprintf(format, args...)
+	return &copied
+}
+
+// WithFields returns a copy of the error carrying per field details
+func (e *Error) WithFields(fields []FieldError) *Error {
+	copied := *e
+	copied.Fields = fields
+	return &copied
+}
+
+// WithRetryAfter returns a copy of the error telling the client when to retry
+func (e *Error) WithRetryAfter(retryAfter time.Duration) *Error {
+	copied := *e
+	copied.RetryAfter = retryAfter
+	return &copied
+}
+
+// From returns the *Error in err's chain, or ErrInternal for anything else
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternal
+}
@@ -0,0 +1,58 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrInvalidRequest is returned for request bodies or queries that fail to bind
+var ErrInvalidRequest = Invalid("invalid_request", "invalid request")
+
+// ErrInvalidID is returned for path parameters that are not a valid uuid
+var ErrInvalidID = Invalid("invalid_id", "invalid id")
+
+// FromBinding turns an error of gin's ShouldBind* into ErrInvalidRequest, with
+// one field error per failed validation rule
+func FromBinding(err error) *Error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return ErrInvalidRequest.WithDetail("%s", err.Error())
+	}
+	fields := make([]FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		fields = append(fields, FieldError{
+			Field:   lowerFirst(fieldErr.Field()),
+			Code:    fieldErr.Tag(),
+			Message: validationMessage(fieldErr),
+		})
+	}
+	return ErrInvalidRequest.WithFields(fields)
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	default:
+		return fmt.Sprintf("failed the %q rule", fieldErr.Tag())
+	}
+}
+
+// lowerFirst maps struct field names to the camelCase names of the JSON API
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,220 @@
+// Package audit records security relevant events in a tamper-evident log
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+)
+
+// Event types
+const (
+	EventUserRegistered       = "user.registered"
+	EventRegistrationTaken    = "registration.email_taken"
+	EventLoginSucceeded       = "login.succeeded"
+	EventLoginFailed          = "login.failed"
+	EventLoginThrottled       = "login.throttled"
+	EventLogout               = "logout"
+	EventPasswordChanged      = "password.changed"
+	EventPasswordReset        = "password.reset"
+	EventEmailChanged         = "email.changed"
+	EventEmailChangeTaken     = "email.change_taken"
+	EventTwoFactorEnabled     = "two_factor.enabled"
+	EventTwoFactorDisabled    = "two_factor.disabled"
+	EventSessionRevoked       = "session.revoked"
+	EventTokensRevoked        = "tokens.revoked"
+	EventRefreshTokenReused   = "refresh_token.reused"
+	EventPersonalTokenCreated = "personal_token.created"
+	EventPersonalTokenRevoked = "personal_token.revoked"
+	EventRolesChanged         = "roles.changed"
+	EventAccountDeleted       = "account.deleted"
+	EventAccountRestored      = "account.restored"
+	EventAccountPurged        = "account.purged"
+	EventInvitationCreated    = "invitation.created"
+	EventInvitationRevoked    = "invitation.revoked"
+	EventInviteQuotaChanged   = "invite_quota.changed"
+)
+
+const (
+	defaultPageSize = 50
+	// events checked per query while verifying the chain
+	verifyBatchSize = 1000
+	// a record outlives the request it was made for
+	recordTimeout = 5 * time.Second
+)
+
+// Event is what callers record, the client is taken from the context
+type Event struct {
+	Type string
+	// ActorID is the user performing the action, uuid.Nil when unknown
+	ActorID uuid.UUID
+	// SubjectID is the user the action applies to, uuid.Nil when it is the actor
+	SubjectID uuid.UUID
+	Metadata  map[string]interface{}
+}
+
+// ChainError reports the first event that does not fit the hash chain
+type ChainError struct {
+	EventID uint64
+	Reason  string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at event %d: %s", e.EventID, e.Reason)
+}
+
+// Recorder appends events to the audit log and reads them back
+type Recorder struct {
+	repo storage.AuditRepository
+}
+
+func NewRecorder(repo storage.AuditRepository) *Recorder {
+	return &Recorder{repo: repo}
+}
+
+// Record appends event to the log. Failures are logged only, an action is
+// not undone because its audit record could not be written.
+func (r *Recorder) Record(ctx context.Context, event Event) {
+	client := clientFrom(ctx)
+	metadata := event.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		log.Printf("记录审计日志失败(%s): %v", event.Type, err)
+		return
+	}
+
+	stored := &models.AuditEvent{
+		CreatedAt: time.Now(),
+		EventType: event.Type,
+		ActorID:   optionalID(event.ActorID),
+		SubjectID: optionalID(event.SubjectID),
+		IP:        client.IP,
+		UserAgent: truncate(client.UserAgent, 512),
+		Metadata:  string(encoded),
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := r.repo.Append(ctx, stored); err != nil {
+		log.Printf("记录审计日志失败(%s): %v", event.Type, err)
+	}
+}
+
+// List returns a page of events for the admin console, newest first
+func (r *Recorder) List(ctx context.Context, query *api.AuditQuery) (*api.AuditListResponse, error) {
+	page, pageSize := query.Page, query.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	filter := storage.AuditFilter{
+		EventType: strings.TrimSpace(query.Type),
+		ActorID:   parseOptionalID(query.ActorID),
+		SubjectID: parseOptionalID(query.SubjectID),
+		From:      query.From,
+		To:        query.To,
+	}
+	events, total, err := r.repo.List(ctx, filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.AuditListResponse{
+		Events:   make([]api.AuditEventResponse, 0, len(events)),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, toEventResponse(&event))
+	}
+	return resp, nil
+}
+
+// Verify walks the whole chain and checks every link and hash. It returns the
+// number of events checked and a *ChainError at the first mismatch.
+func (r *Recorder) Verify(ctx context.Context) (int, error) {
+	checked := 0
+	prevHash := ""
+	var afterID uint64
+	for {
+		events, err := r.repo.ListAfter(ctx, afterID, verifyBatchSize)
+		if err != nil {
+			return checked, err
+		}
+		for i := range events {
+			event := &events[i]
+			if event.PrevHash != prevHash {
+				return checked, &ChainError{EventID: event.ID, Reason: "previous hash does not match, an event before it was changed or removed"}
+			}
+			if event.Hash != event.ComputeHash() {
+				return checked, &ChainError{EventID: event.ID, Reason: "hash does not match the content, the event was changed"}
+			}
+			prevHash = event.Hash
+			afterID = event.ID
+			checked++
+		}
+		if len(events) < verifyBatchSize {
+			return checked, nil
+		}
+	}
+}
+
+func toEventResponse(event *models.AuditEvent) api.AuditEventResponse {
+	resp := api.AuditEventResponse{
+		ID:        event.ID,
+		CreatedAt: event.CreatedAt,
+		EventType: event.EventType,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		Metadata:  json.RawMessage(event.Metadata),
+		Hash:      event.Hash,
+	}
+	if event.ActorID != nil {
+		resp.ActorID = event.ActorID.String()
+	}
+	if event.SubjectID != nil {
+		resp.SubjectID = event.SubjectID.String()
+	}
+	return resp
+}
+
+func optionalID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+// parseOptionalID parses an ID already validated by binding, "" means no filter
+func parseOptionalID(value string) *uuid.UUID {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+func truncate(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+	// do not cut a multi-byte character in half
+	for maxLength > 0 && !utf8.RuneStart(value[maxLength]) {
+		maxLength--
+	}
+	return value[:maxLength]
+}
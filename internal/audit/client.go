@@ -0,0 +1,23 @@
+package audit
+
+import "context"
+
+type clientContextKey struct{}
+
+// Client is the origin of a request, recorded with every event
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// WithClient returns a context carrying the client of the current request
+func WithClient(ctx context.Context, ip string, userAgent string) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, Client{IP: ip, UserAgent: userAgent})
+}
+
+// clientFrom returns the client stored by WithClient, events recorded outside
+// of a request (background jobs, commands) have none
+func clientFrom(ctx context.Context) Client {
+	client, _ := ctx.Value(clientContextKey{}).(Client)
+	return client
+}
@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/apperror"
+)
+
+type Handler struct {
+	recorder *Recorder
+}
+
+func NewHandler(recorder *Recorder) *Handler {
+	return &Handler{recorder: recorder}
+}
+
+func (h *Handler) ListEvents(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var query api.AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.recorder.List(ctx, &query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
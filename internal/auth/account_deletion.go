@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// accounts purged per batch, the purger loops until none is left
+const purgeBatchSize = 100
+
+// DeleteAccount soft deletes the account after checking the password, and the
+// second factor when 2FA is enabled. The user is signed out everywhere and
+// gets an email with a link restoring the account during the grace period.
+func (s *Service) DeleteAccount(ctx context.Context, userID uuid.UUID, req *api.DeleteAccountRequest) (*api.DeleteAccountResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	match, err := s.hashPassword.Compare(ctx, req.Password, user.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare password: %w", err)
+	}
+	if !match {
+		return nil, ErrInvalidCredentials
+	}
+	if user.TwoFactorEnabled {
+		if err := s.checkSecondFactor(ctx, user, req.Code); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if err := s.userRepo.SoftDelete(ctx, user.ID, now); err != nil {
+		return nil, err
+	}
+	if err := s.revokeAllUserTokens(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	purgeAt := now.Add(s.deletionGrace)
+	token, err := s.createActionToken(ctx, user.ID, models.ActionRestoreAccount, s.deletionGrace)
+	if err != nil {
+		return nil, err
+	}
+	// the account is deleted either way, the user can still ask support to restore it
+	if err := s.email.SendAccountDeletion(ctx, user.Email, user.Name, s.appLink("/restore-account", token), purgeAt); err != nil {
+		log.Printf("发送账户删除邮件失败: %v", err)
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventAccountDeleted, ActorID: user.ID, Metadata: map[string]interface{}{"purgeAt": purgeAt}})
+	log.Printf("账户已删除, 等待清理, 用户ID: %s, 清理时间: %s", user.ID, purgeAt.Format(time.RFC3339))
+	return &api.DeleteAccountResponse{PurgeAt: purgeAt}, nil
+}
+
+// RestoreAccount consumes the link of the deletion email and undoes the
+// deletion. The user logs in again afterwards.
+func (s *Service) RestoreAccount(ctx context.Context, req *api.RestoreAccountRequest) (*api.UserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	consumed, err := s.consumeActionToken(ctx, models.ActionRestoreAccount, req.Token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSignedToken) {
+			return nil, ErrInvalidRestoreToken
+		}
+		return nil, err
+	}
+	if err := s.userRepo.Restore(ctx, consumed.UserID); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidRestoreToken
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, consumed.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventAccountRestored, ActorID: user.ID})
+	log.Printf("账户已恢复, 用户ID: %s", user.ID)
+	return s.profileResponse(ctx, user)
+}
+
+// ExportAccount builds a zip archive with the profile of the user in
+// profile.json and all their writing logs in write_logs.json
+func (s *Service) ExportAccount(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	profile, err := s.profileResponse(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.identityRepo.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := s.writeLogRepo.GetLogByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := api.AccountExport{
+		ExportedAt:      time.Now().UTC(),
+		User:            *profile,
+		CreatedAt:       user.CreatedAt,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		Identities:      make([]api.IdentityExport, 0, len(identities)),
+	}
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, api.IdentityExport{
+			Provider:    identity.Provider,
+			Email:       identity.Email,
+			CreatedAt:   identity.CreatedAt,
+			LastLoginAt: identity.LastLoginAt,
+		})
+	}
+	if logs == nil {
+		logs = []*models.WriteLog{}
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	if err := writeJSONFile(archive, "profile.json", export); err != nil {
+		return nil, err
+	}
+	if err := writeJSONFile(archive, "write_logs.json", logs); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	log.Printf("账户数据已导出, 用户ID: %s, 写作记录: %d", user.ID, len(logs))
+	return buf.Bytes(), nil
+}
+
+func writeJSONFile(archive *zip.Writer, name string, value interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// PurgeDeletedAccounts erases the accounts whose grace period ended before
+// now, together with every row belonging to them. It returns how many were purged.
+func (s *Service) PurgeDeletedAccounts(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	for {
+		users, err := s.userRepo.ListDeletedBefore(ctx, now.Add(-s.deletionGrace), purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, user := range users {
+			if err := s.userRepo.Purge(ctx, user.ID); err != nil {
+				return purged, err
+			}
+			if err := s.loginGuard.forget(ctx, user.Email); err != nil {
+				log.Printf("清理登录失败记录失败: %v", err)
+			}
+			// the audit log is append only, earlier events of the user stay
+			s.audit.Record(ctx, audit.Event{Type: audit.EventAccountPurged, SubjectID: user.ID})
+			purged++
+		}
+		if len(users) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// RunAccountPurger calls PurgeDeletedAccounts every interval until ctx is done
+func RunAccountPurger(ctx context.Context, service *Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purged, err := service.PurgeDeletedAccounts(ctx, now)
+			if err != nil {
+				log.Printf("清理已删除账户失败: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("已清理 %d 个已删除账户", purged)
+			}
+		}
+	}
+}
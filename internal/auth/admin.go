@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+const defaultUserPageSize = 20
+
+// SeedRoles creates the built-in roles, or resets their permissions to the
+// ones defined in code
+func (s *Service) SeedRoles(ctx context.Context) error {
+	for name, permissions := range utils.BuiltinRoles {
+		if err := s.roleRepo.Upsert(ctx, &models.Role{
+			Name:        name,
+			Description: "built-in",
+			Permissions: strings.Join(permissions, " "),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GrantRoleByEmail gives the user with email a role, used to bootstrap the
+// first admin from the config or the command line
+func (s *Service) GrantRoleByEmail(ctx context.Context, email string, roleName string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	roles, err := s.roleRepo.GetByNames(ctx, []string{roleName})
+	if err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return ErrUnknownRole
+	}
+	return s.roleRepo.Grant(ctx, user.ID, roles[0].ID, nil)
+}
+
+// userRoles returns the role names of the user and the permissions they grant
+func (s *Service) userRoles(ctx context.Context, userID uuid.UUID) ([]string, []string, error) {
+	roles, err := s.roleRepo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(roles))
+	var permissions []string
+	for _, role := range roles {
+		names = append(names, role.Name)
+		for _, permission := range strings.Fields(role.Permissions) {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return names, permissions, nil
+}
+
+// ListUsers returns a page of users for the admin console
+func (s *Service) ListUsers(ctx context.Context, query *api.ListUsersQuery) (*api.UserListResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	page, pageSize := query.Page, query.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultUserPageSize
+	}
+	users, total, err := s.userRepo.List(ctx, strings.TrimSpace(query.Search), (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.UserListResponse{
+		Users:    make([]api.AdminUserResponse, 0, len(users)),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for i := range users {
+		user, err := s.toAdminUserResponse(ctx, &users[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Users = append(resp.Users, *user)
+	}
+	return resp, nil
+}
+
+// GetUser returns one user with their roles
+func (s *Service) GetUser(ctx context.Context, userID uuid.UUID) (*api.AdminUserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return s.toAdminUserResponse(ctx, user)
+}
+
+// SetUserRoles replaces the roles of a user. The access tokens of the user are
+// revoked so the new permissions apply from the next token refresh on.
+func (s *Service) SetUserRoles(ctx context.Context, actorID uuid.UUID, userID uuid.UUID, req *api.SetUserRolesRequest) (*api.AdminUserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if actorID == userID && !slices.Contains(req.Roles, utils.RoleAdmin) {
+		return nil, ErrCannotRemoveOwnAdmin
+	}
+
+	roles, err := s.roleRepo.GetByNames(ctx, req.Roles)
+	if err != nil {
+		return nil, err
+	}
+	roleIDs := make([]uuid.UUID, 0, len(roles))
+	for _, name := range req.Roles {
+		index := slices.IndexFunc(roles, func(role models.Role) bool { return role.Name == name })
+		if index < 0 {
+			return nil, ErrUnknownRole.WithDetail("%q", name)
+		}
+		if !slices.Contains(roleIDs, roles[index].ID) {
+			roleIDs = append(roleIDs, roles[index].ID)
+		}
+	}
+	if err := s.roleRepo.SetForUser(ctx, user.ID, roleIDs, &actorID); err != nil {
+		return nil, err
+	}
+
+	// tokens carry the roles, refreshed ones pick up the new set
+	if err := s.revokeAccessTokens(ctx, user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventRolesChanged, ActorID: actorID, SubjectID: user.ID, Metadata: map[string]interface{}{"roles": req.Roles}})
+	log.Printf("用户角色已更新: %s, 角色: %v, 操作人: %s", user.Email, req.Roles, actorID)
+	return s.toAdminUserResponse(ctx, user)
+}
+
+// SignOutUser revokes every session and token of a user on behalf of actorID
+func (s *Service) SignOutUser(ctx context.Context, actorID uuid.UUID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if err := s.revokeAllUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventTokensRevoked, ActorID: actorID, SubjectID: userID, Metadata: map[string]interface{}{"reason": "admin_sign_out"}})
+	return nil
+}
+
+// ListRoles returns every role that can be assigned
+func (s *Service) ListRoles(ctx context.Context) ([]api.RoleResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	roles, err := s.roleRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]api.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		resp = append(resp, api.RoleResponse{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: strings.Fields(role.Permissions),
+		})
+	}
+	return resp, nil
+}
+
+func (s *Service) toAdminUserResponse(ctx context.Context, user *models.User) (*api.AdminUserResponse, error) {
+	roles, _, err := s.userRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &api.AdminUserResponse{
+		UserResponse: toUserResponse(user),
+		CreatedAt:    user.CreatedAt,
+		DeletedAt:    user.DeletedAt,
+		InviteQuota:  user.InviteQuota,
+	}
+	if user.InvitedByID != nil {
+		resp.InvitedBy = user.InvitedByID.String()
+	}
+	resp.Roles = roles
+	return resp, nil
+}
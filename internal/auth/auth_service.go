@@ -5,54 +5,176 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/config"
+	"github.com/jinxinyu/go_backend/internal/email"
 	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/oidc"
+	"github.com/jinxinyu/go_backend/internal/password"
 	"github.com/jinxinyu/go_backend/internal/storage"
 	"github.com/jinxinyu/go_backend/internal/utils"
 )
 
 type Service struct {
-	userRepo     storage.UserRepository
-	timeout      time.Duration
-	tokenmaker   utils.ToKenGenerator
-	hashPassword utils.HashedPassword
-	//To be soon added: emailservice
+	userRepo          storage.UserRepository
+	refreshTokenRepo  storage.RefreshTokenRepository
+	revocations       storage.RevocationStore
+	actionTokenRepo   storage.ActionTokenRepository
+	recoveryCodeRepo  storage.RecoveryCodeRepository
+	identityRepo      storage.IdentityRepository
+	personalTokenRepo storage.PersonalAccessTokenRepository
+	sessionRepo       storage.SessionRepository
+	roleRepo          storage.RoleRepository
+	writeLogRepo      storage.WriteLogRepository
+	invitationRepo    storage.InvitationRepository
+	loginGuard        *loginGuard
+	timeout           time.Duration
+	accessTokenTTL    time.Duration
+	refreshTokenTTL   time.Duration
+	tokenmaker        utils.ToKenGenerator
+	hashPassword      utils.HashedPassword
+	signer            utils.LinkSigner
+	passwordPolicy    *password.Policy
+	email             *email.Service
+	oidc              *oidc.Registry
+	audit             *audit.Recorder
+
+	appBaseURL            string
+	verificationTTL       time.Duration
+	passwordResetTTL      time.Duration
+	requireVerifiedEmail  bool
+	resendCooldown        time.Duration
+	resendMaxPerHour      int
+	twoFactorIssuer       string
+	twoFactorChallengeTTL time.Duration
+	oidcStateTTL          time.Duration
+	deletionGrace         time.Duration
+	magicLinkTTL          time.Duration
+	registrationMode      string
+	hideRegisteredEmails  bool
+	invitationTTL         time.Duration
+	defaultInviteQuota    int
+	// compared against when the login email is unknown, see compareDummyPassword
+	dummyPasswordHash string
+}
+
+// Dependencies bundles the repositories and utilities the auth service is built from
+type Dependencies struct {
+	UserRepo          storage.UserRepository
+	RefreshTokenRepo  storage.RefreshTokenRepository
+	Revocations       storage.RevocationStore
+	ActionTokenRepo   storage.ActionTokenRepository
+	RecoveryCodeRepo  storage.RecoveryCodeRepository
+	LoginAttempts     storage.LoginAttemptStore
+	IdentityRepo      storage.IdentityRepository
+	PersonalTokenRepo storage.PersonalAccessTokenRepository
+	SessionRepo       storage.SessionRepository
+	RoleRepo          storage.RoleRepository
+	WriteLogRepo      storage.WriteLogRepository
+	InvitationRepo    storage.InvitationRepository
+	TokenMaker        utils.ToKenGenerator
+	HashPassword      utils.HashedPassword
+	Signer            utils.LinkSigner
+	PasswordPolicy    *password.Policy
+	Email             *email.Service
+	OIDC              *oidc.Registry
+	Audit             *audit.Recorder
 }
 
-func NewService(userRepo storage.UserRepository, tokenmaker utils.ToKenGenerator, hashPassword utils.HashedPassword) *Service {
-	return &Service{
-		userRepo:     userRepo,
-		tokenmaker:   tokenmaker,
-		hashPassword: hashPassword,
-		timeout:      time.Second * 60, // 设置默认超时时间为60秒
+func NewService(cfg *config.Config, deps Dependencies) *Service {
+	s := &Service{
+		userRepo:          deps.UserRepo,
+		refreshTokenRepo:  deps.RefreshTokenRepo,
+		revocations:       deps.Revocations,
+		actionTokenRepo:   deps.ActionTokenRepo,
+		recoveryCodeRepo:  deps.RecoveryCodeRepo,
+		identityRepo:      deps.IdentityRepo,
+		personalTokenRepo: deps.PersonalTokenRepo,
+		sessionRepo:       deps.SessionRepo,
+		roleRepo:          deps.RoleRepo,
+		writeLogRepo:      deps.WriteLogRepo,
+		invitationRepo:    deps.InvitationRepo,
+		loginGuard:        newLoginGuard(deps.LoginAttempts, cfg),
+		tokenmaker:        deps.TokenMaker,
+		hashPassword:      deps.HashPassword,
+		signer:            deps.Signer,
+		passwordPolicy:    deps.PasswordPolicy,
+		email:             deps.Email,
+		oidc:              deps.OIDC,
+		audit:             deps.Audit,
+		timeout:           time.Second * 60, // 设置默认超时时间为60秒
+		accessTokenTTL:    time.Duration(cfg.JWTExpirationMinutes) * time.Minute,
+		refreshTokenTTL:   time.Duration(cfg.RefreshTokenExpirationHours) * time.Hour,
+
+		appBaseURL:            strings.TrimRight(cfg.AppBaseURL, "/"),
+		verificationTTL:       time.Duration(cfg.EmailVerificationExpirationHours) * time.Hour,
+		passwordResetTTL:      time.Duration(cfg.PasswordResetExpirationMinutes) * time.Minute,
+		requireVerifiedEmail:  cfg.EmailVerificationRequired,
+		resendCooldown:        time.Duration(cfg.EmailResendCooldownSeconds) * time.Second,
+		resendMaxPerHour:      cfg.EmailResendMaxPerHour,
+		twoFactorIssuer:       cfg.TwoFactorIssuer,
+		twoFactorChallengeTTL: time.Duration(cfg.TwoFactorChallengeMinutes) * time.Minute,
+		oidcStateTTL:          time.Duration(cfg.OIDCStateMinutes) * time.Minute,
+		deletionGrace:         time.Duration(cfg.AccountDeletionGraceDays) * 24 * time.Hour,
+		magicLinkTTL:          time.Duration(cfg.MagicLinkExpirationMinutes) * time.Minute,
+		registrationMode:      cfg.RegistrationMode,
+		hideRegisteredEmails:  cfg.RegistrationHideExistingEmails,
+		invitationTTL:         time.Duration(cfg.InvitationExpirationDays) * 24 * time.Hour,
+		defaultInviteQuota:    cfg.InviteDefaultQuota,
 	}
+	s.dummyPasswordHash = newDummyPasswordHash(deps.HashPassword)
+	return s
 }
 
+// RegisterUser creates an account and sends its verification email. With
+// registered emails hidden, a taken address is not an error: the owner is
+// told by email instead and the returned user is nil.
 func (s *Service) RegisterUser(ctx context.Context, req *api.RegisterRequest) (*models.User, error) {
+	if s.registrationMode == config.RegistrationClosed {
+		return nil, ErrRegistrationClosed
+	}
+	if err := s.passwordPolicy.Validate(ctx, req.Password, req.Email, req.Name); err != nil {
+		return nil, err
+	}
+	// checked before the email, a taken address must not change the answer
+	invitation, err := s.checkInvitation(ctx, req.InviteCode)
+	if err != nil {
+		return nil, err
+	}
 
 	//check if the email already exists
 	existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err == nil && existingUser != nil {
-		return nil, fmt.Errorf("email already exists")
+		return nil, s.registrationTaken(ctx, existingUser, req.Password, invitation)
 	} else if err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
 		log.Printf("查询用户时发生错误: %v", err)
 		return nil, fmt.Errorf("failed to check existing user: %w", err)
 	}
 
-	hashedPassword, err := s.hashPassword.Hash(req.Password)
+	hashedPassword, err := s.hashPassword.Hash(ctx, req.Password)
 	if err != nil {
 		log.Printf("密码加密失败: %v", err)
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	user := &models.User{
-		ID:       uuid.New(),
-		Name:     req.Name,
-		Email:    req.Email,
-		Password: hashedPassword,
+		ID:          uuid.New(),
+		Name:        req.Name,
+		Email:       req.Email,
+		Password:    hashedPassword,
+		InviteQuota: s.defaultInviteQuota,
+	}
+	if invitation != nil {
+		// taken only now, a registration refused above does not use up the code
+		if invitation, err = s.redeemInvitation(ctx, invitation); err != nil {
+			return nil, err
+		}
+		user.InvitedByID, user.InvitationID = invitation.InviterID, &invitation.ID
 	}
 
 	// add retry logic
@@ -68,7 +190,8 @@ func (s *Service) RegisterUser(ctx context.Context, req *api.RegisterRequest) (*
 		createErr = s.userRepo.Create(ctx, user)
 		log.Printf("数据库创建操作耗时: %v", time.Since(startDbOp))
 
-		if createErr == nil {
+		// retrying cannot help once the address was registered concurrently
+		if createErr == nil || errors.Is(createErr, storage.ErrEmailTaken) {
 			break
 		}
 
@@ -76,46 +199,351 @@ func (s *Service) RegisterUser(ctx context.Context, req *api.RegisterRequest) (*
 	}
 
 	if createErr != nil {
+		if invitation != nil {
+			if err := s.invitationRepo.Release(ctx, invitation.ID); err != nil {
+				log.Printf("归还邀请码使用次数失败: %v", err)
+			}
+		}
+		if errors.Is(createErr, storage.ErrEmailTaken) {
+			owner, err := s.userRepo.GetByEmail(ctx, req.Email)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get user by email: %w", err)
+			}
+			return nil, s.registrationTaken(ctx, owner, req.Password, invitation)
+		}
 		return nil, fmt.Errorf("failed to create user: %w", createErr)
 	}
 
+	metadata := map[string]interface{}{"method": "password"}
+	if invitation != nil {
+		metadata["invitationId"] = invitation.ID
+		if invitation.InviterID != nil {
+			metadata["invitedBy"] = *invitation.InviterID
+		}
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventUserRegistered, ActorID: user.ID, Metadata: metadata})
+
+	// the account exists at this point, a failed email can be retried through the resend endpoint
+	if s.hideRegisteredEmails {
+		// sent in the background like the notice for a taken address, so the
+		// response time does not tell the two apart
+		go func() {
+			sendCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := s.sendVerificationEmail(sendCtx, user); err != nil {
+				log.Printf("发送验证邮件失败: %v", err)
+			}
+		}()
+	} else if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("发送验证邮件失败: %v", err)
+	}
+
 	return user, nil
 }
 
-func (s *Service) LoginUser(ctx context.Context, req *api.LoginRequest) (string, *models.User, error) {
+func (s *Service) LoginUser(ctx context.Context, req *api.LoginRequest, client ClientInfo) (*api.LoginResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
 	log.Printf("尝试登录用户: %s", req.Email)
 	startTime := time.Now()
 
+	// refuse throttled attempts before paying for a password hash
+	if err := s.loginGuard.check(ctx, req.Email, client.IP); err != nil {
+		log.Printf("登录尝试被限制: %s, IP: %s, %v", req.Email, client.IP, err)
+		s.audit.Record(ctx, audit.Event{Type: audit.EventLoginThrottled, Metadata: map[string]interface{}{"email": req.Email}})
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		log.Printf("查询用户失败: %v", err)
-		return "", nil, fmt.Errorf("failed to get user by email: %w", err)
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			// take as long as a wrong password would
+			if err := s.compareDummyPassword(ctx, req.Password); err != nil {
+				return nil, err
+			}
+			s.recordLoginFailure(ctx, req.Email, client, nil)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 	log.Printf("成功查询到用户: %s, 用户ID: %s", user.Email, user.ID)
 
 	log.Printf("开始验证密码")
-	match, err := s.hashPassword.Compare(req.Password, user.Password)
+	match, err := s.hashPassword.Compare(ctx, req.Password, user.Password)
 	log.Printf("密码验证结果: 匹配=%v, 错误=%v", match, err)
 
 	if err != nil {
 		log.Printf("密码比较失败: %v", err)
-		return "", nil, fmt.Errorf("failed to compare password: %w", err)
+		return nil, fmt.Errorf("failed to compare password: %w", err)
 	}
 
 	if !match {
 		log.Printf("密码不匹配")
-		return "", nil, fmt.Errorf("invalid credentials")
+		s.recordLoginFailure(ctx, req.Email, client, user)
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.loginGuard.recordSuccess(ctx, user.Email); err != nil {
+		log.Printf("重置登录失败计数失败: %v", err)
+	}
+	if s.hashPassword.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	if user.DeletedAt != nil {
+		log.Printf("账户已删除, 拒绝登录: %s", user.Email)
+		return nil, ErrAccountDeleted
+	}
+	if s.requireVerifiedEmail && !user.EmailVerified {
+		log.Printf("邮箱未验证, 拒绝登录: %s", user.Email)
+		return nil, ErrEmailNotVerified
+	}
+
+	if user.TwoFactorEnabled {
+		log.Printf("密码验证通过, 等待两步验证: %s", user.Email)
+		return s.twoFactorChallenge(ctx, user)
 	}
 
-	token, err := s.tokenmaker.GenerateToken(user.ID, user.Email)
+	resp, err := s.startSession(ctx, user, client, "password")
 	if err != nil {
 		log.Printf("生成令牌失败: %v", err)
-		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
 
 	log.Printf("登录成功，用时: %v", time.Since(startTime))
-	return token, user, nil
+	return resp, nil
+}
+
+// rehashPassword upgrades a hash made with outdated parameters while the plain
+// password is at hand. Failing to do so does not fail the login.
+func (s *Service) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hashedPassword, err := s.hashPassword.Hash(ctx, password)
+	if err != nil {
+		log.Printf("重新计算密码哈希失败: %v", err)
+		return
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		log.Printf("更新密码哈希失败: %v", err)
+		return
+	}
+	user.Password = hashedPassword
+	log.Printf("密码哈希已升级到当前参数: %s", user.Email)
+}
+
+// recordLoginFailure counts a failed attempt and tells the owner when it locked the account
+func (s *Service) recordLoginFailure(ctx context.Context, email string, client ClientInfo, user *models.User) {
+	event := audit.Event{Type: audit.EventLoginFailed, Metadata: map[string]interface{}{"email": email}}
+	if user != nil {
+		event.ActorID = user.ID
+	}
+	s.audit.Record(ctx, event)
+
+	locked, err := s.loginGuard.recordFailure(ctx, email, client.IP)
+	if err != nil {
+		log.Printf("记录登录失败次数失败: %v", err)
+		return
+	}
+	if !locked || user == nil {
+		return
+	}
+
+	log.Printf("账户因多次登录失败被临时锁定: %s, IP: %s", user.Email, client.IP)
+	until := time.Now().Add(s.loginGuard.lockDuration)
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.email.SendAccountLocked(sendCtx, user.Email, user.Name, until); err != nil {
+			log.Printf("发送账户锁定通知失败: %v", err)
+		}
+	}()
+}
+
+// RefreshToken rotates a refresh token: the presented token is marked as used
+// and a new access/refresh pair of the same family is returned. Presenting a
+// token that was already used revokes the whole family.
+func (s *Service) RefreshToken(ctx context.Context, req *api.RefreshRequest, client ClientInfo) (*api.LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	stored, err := s.refreshTokenRepo.GetByHash(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if stored.UsedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, stored, now)
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// only one concurrent rotation may win, the loser is treated as reuse
+	marked, err := s.refreshTokenRepo.MarkUsed(ctx, stored.ID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if !marked {
+		return nil, s.revokeReusedFamily(ctx, stored, now)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	if err := s.extendSession(ctx, user.ID, stored.FamilyID, client, now); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// Logout revokes the access token and the session of the caller, and
+// optionally the refresh token family given in the request or every token
+// of the user.
+func (s *Service) Logout(ctx context.Context, claims *utils.Claims, req *api.LogoutRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	now := time.Now()
+	expiresAt := now.Add(s.accessTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if err := s.revocations.Revoke(ctx, claims.ID, claims.UserID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	if claims.SessionID != uuid.Nil {
+		if err := s.endSession(ctx, claims.UserID, claims.SessionID, now); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+
+	if req.RefreshToken != "" {
+		stored, err := s.refreshTokenRepo.GetByHash(ctx, utils.HashToken(req.RefreshToken))
+		if err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get refresh token: %w", err)
+		}
+		// a token of another user is silently ignored
+		if err == nil && stored.UserID == claims.UserID {
+			if err := s.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID, now); err != nil {
+				return fmt.Errorf("failed to revoke refresh token family: %w", err)
+			}
+		}
+	}
+
+	if req.AllDevices {
+		if err := s.revokeAllUserTokens(ctx, claims.UserID); err != nil {
+			return err
+		}
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventLogout, ActorID: claims.UserID, Metadata: map[string]interface{}{
+		"sessionId":  claims.SessionID,
+		"allDevices": req.AllDevices,
+	}})
+	log.Printf("用户已登出: %s, 全部设备: %v", claims.UserID, req.AllDevices)
+	return nil
+}
+
+// revokeAllUserTokens invalidates every access and refresh token issued to the user so far
+func (s *Service) revokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	if err := s.revokeAccessTokens(ctx, userID, now); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, userID, now); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user: %w", err)
+	}
+	if _, err := s.sessionRepo.RevokeAllForUser(ctx, userID, uuid.Nil, now); err != nil {
+		return err
+	}
+	return nil
+}
+
+// revokeAccessTokens invalidates the access tokens issued to the user up to now,
+// sessions stay alive and get fresh tokens on their next refresh
+func (s *Service) revokeAccessTokens(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	// iat has second precision, so a token of the current second cannot be told
+	// apart from one issued just before this call and is refused as well
+	revokedBefore := now.Truncate(time.Second)
+	if err := s.revocations.RevokeAllForUser(ctx, userID, revokedBefore, revokedBefore.Add(s.accessTokenTTL)); err != nil {
+		return fmt.Errorf("failed to revoke access tokens of user: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) revokeReusedFamily(ctx context.Context, stored *models.RefreshToken, now time.Time) error {
+	log.Printf("检测到刷新令牌重复使用, 用户ID: %s, 令牌族: %s", stored.UserID, stored.FamilyID)
+	s.audit.Record(ctx, audit.Event{Type: audit.EventRefreshTokenReused, ActorID: stored.UserID, Metadata: map[string]interface{}{"sessionId": stored.FamilyID}})
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID, now); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	if err := s.sessionRepo.Revoke(ctx, stored.UserID, stored.FamilyID, now); err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// issueTokens creates an access token and a refresh token belonging to the
+// session familyID
+func (s *Service) issueTokens(ctx context.Context, user *models.User, familyID uuid.UUID) (*api.LoginResponse, error) {
+	roles, permissions, err := s.userRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := s.tokenmaker.GenerateToken(utils.TokenSubject{
+		UserID:      user.ID,
+		Email:       user.Email,
+		SessionID:   familyID,
+		Roles:       roles,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	if err := s.refreshTokenRepo.Create(ctx, &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	userResponse := toUserResponse(user)
+	userResponse.Roles = roles
+	return &api.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		User:         &userResponse,
+	}, nil
+}
+
+func toUserResponse(user *models.User) api.UserResponse {
+	return api.UserResponse{
+		ID:               user.ID.String(),
+		Name:             user.Name,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerified,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		TimeZone:         user.TimeZone,
+		PendingEmail:     user.PendingEmail,
+	}
 }
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// newDummyPasswordHash hashes a random password with the current parameters.
+// It returns "" when hashing fails, the login then skips the dummy compare.
+func newDummyPasswordHash(hasher utils.HashedPassword) string {
+	if hasher == nil {
+		return ""
+	}
+	password, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		log.Printf("生成占位密码失败: %v", err)
+		return ""
+	}
+	hash, err := hasher.Hash(context.Background(), password)
+	if err != nil {
+		log.Printf("生成占位密码哈希失败: %v", err)
+		return ""
+	}
+	return hash
+}
+
+// compareDummyPassword costs as much as checking the password of an existing
+// user, so the response time of a login does not reveal whether the email is
+// registered. The result is meaningless and ignored, errors are returned like
+// the real comparison returns them, e.g. when the hashing pool is saturated.
+func (s *Service) compareDummyPassword(ctx context.Context, password string) error {
+	if s.dummyPasswordHash == "" {
+		return nil
+	}
+	if _, err := s.hashPassword.Compare(ctx, password, s.dummyPasswordHash); err != nil {
+		return fmt.Errorf("failed to compare password: %w", err)
+	}
+	return nil
+}
+
+// registrationTaken answers a registration for an address that already has
+// an account. When taken addresses are hidden the invitation is used up like
+// a new account would use it, otherwise a single use code could be tried
+// again to learn that the first attempt did not register anybody.
+func (s *Service) registrationTaken(ctx context.Context, user *models.User, password string, invitation *models.Invitation) error {
+	if !s.hideRegisteredEmails {
+		return ErrEmailExists
+	}
+	if err := s.notifyRegisteredOwner(ctx, user, password); err != nil {
+		return err
+	}
+	if invitation != nil {
+		if _, err := s.redeemInvitation(ctx, invitation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyRegisteredOwner answers a registration for an address that already
+// has an account. It does the work a new account costs and emails the owner
+// a sign in link and a reset link in the background. Only hashing errors are
+// returned, they would have failed the registration of a new account too.
+func (s *Service) notifyRegisteredOwner(ctx context.Context, user *models.User, password string) error {
+	// a new account pays for hashing its password
+	if _, err := s.hashPassword.Hash(ctx, password); err != nil {
+		log.Printf("密码加密失败: %v", err)
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventRegistrationTaken, SubjectID: user.ID})
+	if user.DeletedAt != nil {
+		log.Printf("账户已删除, 不发送注册提醒: %s", user.Email)
+		return nil
+	}
+
+	// repeated attempts share the cooldown of password reset emails
+	recent, err := s.actionTokenRepo.CountCreatedSince(ctx, user.ID, models.ActionPasswordReset, time.Now().Add(-s.resendCooldown))
+	if err != nil {
+		log.Printf("查询重置链接失败: %v", err)
+		return nil
+	}
+	if recent > 0 {
+		log.Printf("注册提醒过于频繁, 已忽略: %s", user.Email)
+		return nil
+	}
+	token, err := s.createActionToken(ctx, user.ID, models.ActionPasswordReset, s.passwordResetTTL)
+	if err != nil {
+		log.Printf("创建重置链接失败: %v", err)
+		return nil
+	}
+	loginLink := s.appBaseURL + "/login"
+	resetLink := s.appLink("/reset-password", token)
+
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.email.SendRegistrationAttempt(sendCtx, user.Email, user.Name, loginLink, resetLink, s.passwordResetTTL); err != nil {
+			log.Printf("发送注册提醒邮件失败: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import "github.com/jinxinyu/go_backend/internal/apperror"
+
+var (
+	// ErrEmailExists is returned when registering an email that already has an account
+	ErrEmailExists = apperror.Conflict("email_exists", "email already exists")
+	// ErrInvalidCredentials is returned when the email is unknown or the password does not match
+	ErrInvalidCredentials = apperror.Unauthorized("invalid_credentials", "invalid credentials")
+	// ErrLoginThrottled is returned, with a retry delay, when a login is refused because of earlier failures
+	ErrLoginThrottled = apperror.TooManyRequests("login_throttled", "too many failed login attempts, please try again later")
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = apperror.Unauthorized("invalid_refresh_token", "invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = apperror.Unauthorized("refresh_token_reused", "refresh token reuse detected")
+	// ErrInvalidVerificationToken is returned for unusable email verification links
+	ErrInvalidVerificationToken = apperror.Invalid("invalid_verification_token", "invalid or expired verification link")
+	// ErrInvalidResetToken is returned for unusable password reset links
+	ErrInvalidResetToken = apperror.Invalid("invalid_reset_token", "invalid or expired password reset link")
+	// ErrEmailNotVerified is returned on login when verification is required and still pending
+	ErrEmailNotVerified = apperror.Forbidden("email_not_verified", "email address is not verified")
+	// ErrInvalidTwoFactorCode is returned when a TOTP or recovery code does not match
+	ErrInvalidTwoFactorCode = apperror.Unauthorized("invalid_two_factor_code", "invalid two-factor code")
+	// ErrInvalidTwoFactorChallenge is returned for unusable two-factor login challenges
+	ErrInvalidTwoFactorChallenge = apperror.Unauthorized("invalid_two_factor_challenge", "invalid or expired two-factor challenge")
+	// ErrTwoFactorAlreadyEnabled is returned when enrolling an account that already uses 2FA
+	ErrTwoFactorAlreadyEnabled = apperror.Conflict("two_factor_already_enabled", "two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnrolled is returned when confirming or disabling 2FA that was never set up
+	ErrTwoFactorNotEnrolled = apperror.Invalid("two_factor_not_enrolled", "two-factor authentication is not set up")
+	// ErrInvalidOIDCState is returned when the callback state does not match the started flow
+	ErrInvalidOIDCState = apperror.Invalid("invalid_oidc_state", "invalid or expired login state")
+	// ErrOIDCLoginFailed is returned when the identity provider rejects the authorization code
+	ErrOIDCLoginFailed = apperror.Unauthorized("oidc_login_failed", "login with identity provider failed")
+	// ErrOIDCProviderUnavailable is returned when the identity provider cannot be reached
+	ErrOIDCProviderUnavailable = apperror.New(apperror.KindBadGateway, "oidc_provider_unavailable", "identity provider is temporarily unavailable")
+	// ErrOIDCEmailNotVerified is returned when the provider does not vouch for the email of a new identity
+	ErrOIDCEmailNotVerified = apperror.Unauthorized("oidc_email_not_verified", "identity provider did not return a verified email")
+	// ErrOIDCAccountUnverified is returned when the provider email belongs to a local account that never verified it
+	ErrOIDCAccountUnverified = apperror.Conflict("oidc_account_unverified", "an account with this email exists but is not verified, verify it first")
+	// ErrInvalidPersonalToken is returned for unknown, expired or revoked personal access tokens
+	ErrInvalidPersonalToken = apperror.Unauthorized("invalid_personal_token", "invalid personal access token")
+	// ErrPersonalTokenNotFound is returned when revoking a token the user does not own
+	ErrPersonalTokenNotFound = apperror.NotFound("personal_token_not_found", "personal access token not found")
+	// ErrInvalidScope is returned when a personal access token is requested with an unknown scope
+	ErrInvalidScope = apperror.Invalid("invalid_scope", "invalid scope")
+	// ErrSessionRevoked is returned for access tokens of a signed out session
+	ErrSessionRevoked = apperror.Unauthorized("session_revoked", "session has been revoked")
+	// ErrSessionNotFound is returned when revoking a session the user does not own
+	ErrSessionNotFound = apperror.NotFound("session_not_found", "session not found")
+	// ErrUserNotFound is returned by admin operations on an unknown user
+	ErrUserNotFound = apperror.NotFound("user_not_found", "user not found")
+	// ErrUnknownRole is returned when assigning a role that does not exist
+	ErrUnknownRole = apperror.Invalid("unknown_role", "unknown role")
+	// ErrCannotRemoveOwnAdmin is returned when admins would lock themselves out
+	ErrCannotRemoveOwnAdmin = apperror.Invalid("cannot_remove_own_admin", "admins cannot remove their own admin role")
+	// ErrInvalidProfileName is returned when a profile update clears the name
+	ErrInvalidProfileName = apperror.Invalid("invalid_name", "name must not be empty")
+	// ErrInvalidTimeZone is returned for a profile time zone that is not a known IANA zone
+	ErrInvalidTimeZone = apperror.Invalid("invalid_time_zone", "unknown time zone")
+	// ErrEmailUnchanged is returned when an email change asks for the current address
+	ErrEmailUnchanged = apperror.Invalid("email_unchanged", "new email is the current email")
+	// ErrInvalidEmailChangeToken is returned for unusable email change links
+	ErrInvalidEmailChangeToken = apperror.Invalid("invalid_email_change_token", "invalid or expired email change link")
+	// ErrInvalidMagicLink is returned for unusable login links, including links opened on another device
+	ErrInvalidMagicLink = apperror.Unauthorized("invalid_magic_link", "invalid or expired login link, request a new one from this browser")
+	// ErrAccountDeleted is returned on login to an account waiting to be purged
+	ErrAccountDeleted = apperror.Forbidden("account_deleted", "account is scheduled for deletion, use the link in the deletion email to restore it")
+	// ErrInvalidRestoreToken is returned for unusable account restore links
+	ErrInvalidRestoreToken = apperror.Invalid("invalid_restore_token", "invalid or expired account restore link")
+	// ErrRegistrationClosed is returned on sign-up while registration is closed
+	ErrRegistrationClosed = apperror.Forbidden("registration_closed", "registration is closed")
+	// ErrInvitationRequired is returned on sign-up without a code while registration is invite only
+	ErrInvitationRequired = apperror.Forbidden("invitation_required", "an invitation code is required to register")
+	// ErrInvalidInvitation is returned for unknown, used up, expired or revoked invitation codes
+	ErrInvalidInvitation = apperror.Invalid("invalid_invitation", "invalid or expired invitation code")
+	// ErrInviteQuotaExceeded is returned when an invitation would invite more people than the quota allows
+	ErrInviteQuotaExceeded = apperror.Forbidden("invite_quota_exceeded", "invite quota exceeded")
+	// ErrInvitationNotFound is returned when revoking an invitation that does not exist or is not the caller's
+	ErrInvitationNotFound = apperror.NotFound("invitation_not_found", "invitation not found")
+)
@@ -2,14 +2,22 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/apperror"
+	"github.com/jinxinyu/go_backend/internal/middleware"
+	"github.com/jinxinyu/go_backend/internal/utils"
 )
 
+// Handlers report failures with c.Error, middleware.ErrorHandler maps them to
+// the status code and JSON envelope of the response.
 type Handler struct {
 	service *Service
 }
@@ -19,6 +27,10 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{service: service}
 }
 
+func clientInfo(c *gin.Context) ClientInfo {
+	return ClientInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
 func (h *Handler) RegisterUser(c *gin.Context) {
 	// 创建一个更长超时的上下文(60秒)
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
@@ -26,7 +38,7 @@ func (h *Handler) RegisterUser(c *gin.Context) {
 
 	var req api.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperror.FromBinding(err))
 		return
 	}
 
@@ -37,8 +49,12 @@ func (h *Handler) RegisterUser(c *gin.Context) {
 
 	log.Printf("创建用户操作耗时: %v", time.Since(startTime))
 	if err != nil {
-		log.Printf("创建用户失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
+		return
+	}
+	// the same answer whether the account was created or the address was taken
+	if h.service.hideRegisteredEmails {
+		c.JSON(http.StatusAccepted, gin.H{"message": "check your email to finish signing up"})
 		return
 	}
 
@@ -51,30 +67,899 @@ func (h *Handler) LoginUser(c *gin.Context) {
 
 	var req api.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.LoginUser(ctx, &req, clientInfo(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) RefreshToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	token, user, err := h.service.LoginUser(ctx, &req)
+	resp, err := h.service.RefreshToken(ctx, &req, clientInfo(c))
 	if err != nil {
-		log.Printf("登录失败: %v", err)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) Logout(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
 
-		// 提供更友好的错误消息
-		if err.Error() == "invalid credentials" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码不正确"})
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	// the body is optional, an empty request only revokes the access token
+	var req api.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(apperror.FromBinding(err))
 			return
 		}
+	}
+
+	if err := h.service.Logout(ctx, claims, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	user, err := h.service.VerifyEmail(ctx, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": toUserResponse(user)})
+}
+
+func (h *Handler) ResendVerification(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	if err := h.service.ResendVerification(ctx, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists and is not verified yet, a new link has been sent"})
+}
+
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	// failures are only logged, the answer must not depend on the account
+	if err := h.service.ForgotPassword(ctx, &req); err != nil {
+		log.Printf("处理忘记密码请求失败: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if an account exists for this email, a reset link has been sent"})
+}
+
+func (h *Handler) ResetPassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	if err := h.service.ResetPassword(ctx, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password has been reset, please log in again"})
+}
+
+func (h *Handler) CompleteTwoFactorLogin(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.CompleteTwoFactorLogin(ctx, &req, clientInfo(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) EnrollTwoFactor(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	resp, err := h.service.EnrollTwoFactor(ctx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) ConfirmTwoFactor(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.ConfirmTwoFactor(ctx, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
 
-		// 检查是否为特定的数据库错误
-		if err.Error() == "failed to get user by email: record not found" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码不正确"})
+	var req api.TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	if err := h.service.DisableTwoFactor(ctx, userID, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+const oidcStateCookie = "oidc_state"
+
+func (h *Handler) ListOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.service.OIDCProviders()})
+}
+
+func (h *Handler) StartOIDCLogin(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	authURL, flowState, err := h.service.StartOIDCLogin(ctx, c.Param("provider"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Lax so the cookie comes back on the top level redirect from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, flowState, 0, "/", "", isHTTPS(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *Handler) FinishOIDCLogin(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	flowState, _ := c.Cookie(oidcStateCookie)
+	// the state is single use whatever the outcome
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/", "", isHTTPS(c), true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		log.Printf("身份提供方返回错误: %s %s", providerErr, c.Query("error_description"))
+		_ = c.Error(ErrOIDCLoginFailed)
+		return
+	}
+	if flowState == "" || c.Query("code") == "" {
+		_ = c.Error(ErrInvalidOIDCState)
+		return
+	}
+
+	resp, err := h.service.FinishOIDCLogin(ctx, c.Param("provider"), flowState, c.Query("state"), c.Query("code"), clientInfo(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+const (
+	magicLinkCookie     = "magic_link_nonce"
+	magicLinkNonceBytes = 32
+)
+
+func (h *Handler) StartMagicLink(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	// keep the nonce of an earlier request: when the cooldown drops this one,
+	// the link already sent to this browser must still match its cookie
+	deviceNonce, err := c.Cookie(magicLinkCookie)
+	if err != nil || !isDeviceNonce(deviceNonce) {
+		deviceNonce, err = utils.GenerateRandomToken(magicLinkNonceBytes)
+		if err != nil {
+			_ = c.Error(err)
 			return
 		}
+	}
+	// failures are only logged, the answer must not depend on the account
+	if err := h.service.StartMagicLink(ctx, &req, deviceNonce); err != nil {
+		log.Printf("处理登录链接请求失败: %v", err)
+	}
+
+	// set in every case, so the response does not tell whether a link was sent
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(magicLinkCookie, deviceNonce, int(h.service.magicLinkTTL.Seconds()), "/", "", isHTTPS(c), true)
+	c.JSON(http.StatusAccepted, gin.H{"message": "if an account exists for this email, a login link has been sent"})
+}
+
+func (h *Handler) FinishMagicLink(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.MagicLinkLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	deviceNonce, _ := c.Cookie(magicLinkCookie)
+	resp, err := h.service.FinishMagicLink(ctx, &req, deviceNonce, clientInfo(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// the link is used up, a new one comes with a new nonce
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(magicLinkCookie, "", -1, "/", "", isHTTPS(c), true)
+	c.JSON(http.StatusOK, resp)
+}
+
+// isDeviceNonce reports whether a cookie value is a nonce StartMagicLink could have set
+func isDeviceNonce(value string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	return err == nil && len(raw) == magicLinkNonceBytes
+}
+
+// isHTTPS reports whether the client reached us over TLS, directly or through a proxy
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+func (h *Handler) CreatePersonalToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.CreatePersonalTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.CreatePersonalToken(ctx, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handler) ListPersonalTokens(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	tokens, err := h.service.ListPersonalTokens(ctx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+func (h *Handler) RevokePersonalToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	tokenID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokePersonalToken(ctx, userID, tokenID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) CreateInvitation(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	invitation, err := h.service.CreateInvitation(ctx, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": invitation})
+}
+
+func (h *Handler) ListMyInvitations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	resp, err := h.service.ListMyInvitations(ctx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) RevokeInvitation(c *gin.Context) {
+	h.revokeInvitation(c, false)
+}
+
+func (h *Handler) ListSessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	sessions, err := h.service.ListSessions(ctx, claims.UserID, claims.SessionID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *Handler) RevokeSession(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	sessionID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeSession(ctx, userID, sessionID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) RevokeOtherSessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	revoked, err := h.service.RevokeOtherSessions(ctx, claims.UserID, claims.SessionID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+func (h *Handler) GetProfile(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	user, err := h.service.GetProfile(ctx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	user, err := h.service.UpdateProfile(ctx, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) ChangePassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	claims, ok := middleware.GetClaims(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	if err := h.service.ChangePassword(ctx, claims, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ChangeEmail(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	user, err := h.service.ChangeEmail(ctx, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"user": user, "message": "a confirmation link has been sent to the new address"})
+}
+
+func (h *Handler) ConfirmEmailChange(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	user, err := h.service.ConfirmEmailChange(ctx, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.DeleteAccount(ctx, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, resp)
+}
+
+func (h *Handler) RestoreAccount(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req api.RestoreAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	user, err := h.service.RestoreAccount(ctx, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) ExportAccount(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	archive, err := h.service.ExportAccount(ctx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	filename := fmt.Sprintf("write-export-%s.zip", time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var query api.ListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.ListUsers(ctx, &query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetUser(ctx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) SetUserRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	userID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req api.SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	user, err := h.service.SetUserRoles(ctx, actorID, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) SignOutUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	userID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.SignOutUser(ctx, actorID, userID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ListRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	roles, err := h.service.ListRoles(ctx)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *Handler) SetInviteQuota(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	userID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req api.SetInviteQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	user, err := h.service.SetInviteQuota(ctx, actorID, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *Handler) ListInvitations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var query api.ListInvitationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.ListInvitations(ctx, &query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AdminCreateInvitation(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	invitation, err := h.service.AdminCreateInvitation(ctx, actorID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": invitation})
+}
+
+func (h *Handler) AdminRevokeInvitation(c *gin.Context) {
+	h.revokeInvitation(c, true)
+}
+
+func (h *Handler) revokeInvitation(c *gin.Context, anyInviter bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	invitationID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
 
-		// 其他服务器内部错误
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录时发生错误，请稍后再试"})
+	if err := h.service.RevokeInvitation(ctx, actorID, invitationID, anyInviter); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token, "user": user})
+	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/config"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+const (
+	// 12 URL-safe characters, short enough to be typed in
+	invitationCodeBytes       = 9
+	defaultInvitationPageSize = 20
+)
+
+// CreateInvitation creates an invitation of the user, limited by their invite
+// quota: the seats of all their usable invitations plus the ones already used
+// must fit in it
+func (s *Service) CreateInvitation(ctx context.Context, userID uuid.UUID, req *api.CreateInvitationRequest) (*api.InvitationResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	invitation, err := s.newInvitation(userID, req)
+	if err != nil {
+		return nil, err
+	}
+	quota, reserved, err := s.invitationRepo.CreateWithinQuota(ctx, invitation, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrInviteQuotaExceeded) {
+			return nil, ErrInviteQuotaExceeded.WithDetail("%d of %d invitations left", max(int64(quota)-reserved, 0), quota)
+		}
+		return nil, err
+	}
+	return s.invitationCreated(ctx, invitation), nil
+}
+
+// AdminCreateInvitation creates an invitation without quota, e.g. for a batch
+// of beta testers
+func (s *Service) AdminCreateInvitation(ctx context.Context, actorID uuid.UUID, req *api.CreateInvitationRequest) (*api.InvitationResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	invitation, err := s.newInvitation(actorID, req)
+	if err != nil {
+		return nil, err
+	}
+	invitation.AdminIssued = true
+	if err := s.invitationRepo.Create(ctx, invitation); err != nil {
+		return nil, err
+	}
+	return s.invitationCreated(ctx, invitation), nil
+}
+
+func (s *Service) newInvitation(inviterID uuid.UUID, req *api.CreateInvitationRequest) (*models.Invitation, error) {
+	code, err := utils.GenerateRandomToken(invitationCodeBytes)
+	if err != nil {
+		return nil, err
+	}
+	ttl := s.invitationTTL
+	if req.ExpiresInDays > 0 {
+		ttl = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	return &models.Invitation{
+		ID:        uuid.New(),
+		Code:      code,
+		InviterID: &inviterID,
+		MaxUses:   invitationMaxUses(req),
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// invitationCreated records a stored invitation and returns its response
+func (s *Service) invitationCreated(ctx context.Context, invitation *models.Invitation) *api.InvitationResponse {
+	s.audit.Record(ctx, audit.Event{Type: audit.EventInvitationCreated, ActorID: *invitation.InviterID, Metadata: map[string]interface{}{
+		"invitationId": invitation.ID,
+		"maxUses":      invitation.MaxUses,
+	}})
+	log.Printf("邀请码已创建, 邀请人: %s, 可用次数: %d", *invitation.InviterID, invitation.MaxUses)
+	resp := s.toInvitationResponse(invitation)
+	return &resp
+}
+
+// ListMyInvitations returns the invitations of the user with what is left of their quota
+func (s *Service) ListMyInvitations(ctx context.Context, userID uuid.UUID) (*api.MyInvitationsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	invitations, err := s.invitationRepo.ListByInviter(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := s.invitationRepo.ReservedSeats(ctx, user.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.MyInvitationsResponse{
+		Invitations: make([]api.InvitationResponse, 0, len(invitations)),
+		Quota:       user.InviteQuota,
+		Remaining:   max(user.InviteQuota-int(reserved), 0),
+	}
+	for i := range invitations {
+		resp.Invitations = append(resp.Invitations, s.toInvitationResponse(&invitations[i]))
+	}
+	return resp, nil
+}
+
+// ListInvitations returns a page of all invitations for the admin console
+func (s *Service) ListInvitations(ctx context.Context, query *api.ListInvitationsQuery) (*api.InvitationListResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	page, pageSize := query.Page, query.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultInvitationPageSize
+	}
+	invitations, total, err := s.invitationRepo.List(ctx, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.InvitationListResponse{
+		Invitations: make([]api.InvitationResponse, 0, len(invitations)),
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+	}
+	for i := range invitations {
+		resp.Invitations = append(resp.Invitations, s.toInvitationResponse(&invitations[i]))
+	}
+	return resp, nil
+}
+
+// RevokeInvitation revokes an invitation of the user, or any invitation when
+// anyInviter is set for admins. Accounts already created with it stay.
+func (s *Service) RevokeInvitation(ctx context.Context, actorID uuid.UUID, invitationID uuid.UUID, anyInviter bool) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	inviterID := &actorID
+	if anyInviter {
+		inviterID = nil
+	}
+	if err := s.invitationRepo.Revoke(ctx, invitationID, inviterID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrInvitationNotFound
+		}
+		return err
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventInvitationRevoked, ActorID: actorID, Metadata: map[string]interface{}{"invitationId": invitationID}})
+	return nil
+}
+
+// SetInviteQuota changes how many people a user may invite
+func (s *Service) SetInviteQuota(ctx context.Context, actorID uuid.UUID, userID uuid.UUID, req *api.SetInviteQuotaRequest) (*api.AdminUserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if err := s.userRepo.SetInviteQuota(ctx, user.ID, *req.Quota); err != nil {
+		return nil, err
+	}
+	user.InviteQuota = *req.Quota
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventInviteQuotaChanged, ActorID: actorID, SubjectID: user.ID, Metadata: map[string]interface{}{"quota": user.InviteQuota}})
+	return s.toAdminUserResponse(ctx, user)
+}
+
+// checkInvitation looks up the code of a registration. It returns nil without
+// a code while registration is open, and does not take a use yet.
+func (s *Service) checkInvitation(ctx context.Context, code string) (*models.Invitation, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		if s.registrationMode == config.RegistrationInvite {
+			return nil, ErrInvitationRequired
+		}
+		return nil, nil
+	}
+	invitation, err := s.invitationRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	if !invitation.Usable(time.Now()) {
+		return nil, ErrInvalidInvitation
+	}
+	return invitation, nil
+}
+
+// redeemInvitation takes one use of the invitation, it may have been used up
+// by a concurrent registration since checkInvitation
+func (s *Service) redeemInvitation(ctx context.Context, invitation *models.Invitation) (*models.Invitation, error) {
+	redeemed, err := s.invitationRepo.Redeem(ctx, invitation.ID, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	return redeemed, nil
+}
+
+func (s *Service) toInvitationResponse(invitation *models.Invitation) api.InvitationResponse {
+	resp := api.InvitationResponse{
+		ID:          invitation.ID.String(),
+		Code:        invitation.Code,
+		Link:        s.appBaseURL + "/register?invite=" + url.QueryEscape(invitation.Code),
+		MaxUses:     invitation.MaxUses,
+		Uses:        invitation.Uses,
+		ExpiresAt:   invitation.ExpiresAt,
+		RevokedAt:   invitation.RevokedAt,
+		AdminIssued: invitation.AdminIssued,
+		CreatedAt:   invitation.CreatedAt,
+	}
+	if invitation.InviterID != nil {
+		resp.InviterID = invitation.InviterID.String()
+	}
+	return resp
+}
+
+func invitationMaxUses(req *api.CreateInvitationRequest) int {
+	if req.MaxUses < 1 {
+		return 1
+	}
+	return req.MaxUses
+}
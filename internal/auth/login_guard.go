@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/config"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+)
+
+// ClientInfo describes where a request comes from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// loginGuard slows down password guessing. Every key (the email and the client
+// IP) gets a few free failures, after which each further failure doubles the
+// wait before the next attempt. An email that reaches the lockout threshold is
+// locked for a fixed duration.
+type loginGuard struct {
+	store         storage.LoginAttemptStore
+	emailFree     int
+	ipFree        int
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	lockThreshold int
+	lockDuration  time.Duration
+	failureWindow time.Duration
+}
+
+func newLoginGuard(store storage.LoginAttemptStore, cfg *config.Config) *loginGuard {
+	return &loginGuard{
+		store:         store,
+		emailFree:     cfg.LoginFreeAttempts,
+		ipFree:        cfg.LoginIPFreeAttempts,
+		baseDelay:     time.Duration(cfg.LoginBackoffBaseSeconds) * time.Second,
+		maxDelay:      time.Duration(cfg.LoginBackoffMaxSeconds) * time.Second,
+		lockThreshold: cfg.LoginLockoutThreshold,
+		lockDuration:  time.Duration(cfg.LoginLockoutMinutes) * time.Minute,
+		failureWindow: time.Duration(cfg.LoginAttemptWindowMinutes) * time.Minute,
+	}
+}
+
+func emailKey(email string) string {
+	return "email:" + models.NormalizeEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// check returns ErrLoginThrottled, with the wait as retry delay, when the email or the IP must wait
+func (g *loginGuard) check(ctx context.Context, email string, ip string) error {
+	now := time.Now()
+	var wait time.Duration
+	for key, free := range map[string]int{emailKey(email): g.emailFree, ipKey(ip): g.ipFree} {
+		attempt, err := g.store.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, storage.ErrRecordNotFound) {
+				continue
+			}
+			return err
+		}
+		if d := g.blockedFor(attempt, free, now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return ErrLoginThrottled.WithRetryAfter(wait)
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt and reports whether it locked the account
+func (g *loginGuard) recordFailure(ctx context.Context, email string, ip string) (bool, error) {
+	now := time.Now()
+	if _, err := g.store.RegisterFailure(ctx, ipKey(ip), now, g.failureWindow); err != nil {
+		return false, err
+	}
+	attempt, err := g.store.RegisterFailure(ctx, emailKey(email), now, g.failureWindow)
+	if err != nil {
+		return false, err
+	}
+	if g.lockThreshold <= 0 || attempt.Failures < g.lockThreshold {
+		return false, nil
+	}
+	if err := g.store.Lock(ctx, emailKey(email), now.Add(g.lockDuration)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recordSuccess clears the counter of the email. The IP counter is kept, so a
+// valid account cannot be used to reset the budget of an IP spraying guesses.
+func (g *loginGuard) recordSuccess(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, emailKey(email))
+}
+
+// forget drops the counter of the email, e.g. when the account is purged
+func (g *loginGuard) forget(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, emailKey(email))
+}
+
+func (g *loginGuard) blockedFor(attempt *models.LoginAttempt, free int, now time.Time) time.Duration {
+	var until time.Time
+	if attempt.LockedUntil != nil {
+		until = *attempt.LockedUntil
+	}
+	if attempt.Failures >= free && attempt.LastFailureAt.After(now.Add(-g.failureWindow)) {
+		if backoffUntil := attempt.LastFailureAt.Add(g.backoff(attempt.Failures - free)); backoffUntil.After(until) {
+			until = backoffUntil
+		}
+	}
+	if until.After(now) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// backoff is baseDelay * 2^n, capped at maxDelay
+func (g *loginGuard) backoff(n int) time.Duration {
+	delay := float64(g.baseDelay) * math.Pow(2, float64(n))
+	if delay > float64(g.maxDelay) {
+		return g.maxDelay
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// StartMagicLink emails a single use login link bound to deviceNonce, a random
+// value the caller keeps in a cookie of the requesting browser. Like
+// ForgotPassword it behaves the same whether or not the account exists.
+func (s *Service) StartMagicLink(ctx context.Context, req *api.MagicLinkRequest, deviceNonce string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if user.DeletedAt != nil {
+		log.Printf("账户已删除, 忽略登录链接请求: %s", user.Email)
+		return nil
+	}
+
+	recent, err := s.actionTokenRepo.CountCreatedSince(ctx, user.ID, models.ActionMagicLogin, time.Now().Add(-s.resendCooldown))
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		log.Printf("登录链接请求过于频繁, 已忽略: %s", user.Email)
+		return nil
+	}
+
+	token, err := s.createBoundActionToken(ctx, user.ID, models.ActionMagicLogin, utils.HashToken(deviceNonce), s.magicLinkTTL)
+	if err != nil {
+		return err
+	}
+	link := s.appLink("/magic-login", token)
+
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.email.SendMagicLink(sendCtx, user.Email, user.Name, link, s.magicLinkTTL); err != nil {
+			log.Printf("发送登录链接邮件失败: %v", err)
+		}
+	}()
+	return nil
+}
+
+// FinishMagicLink exchanges a login link for tokens. It only succeeds with the
+// nonce of the browser that asked for the link, so a forwarded or intercepted
+// email is useless elsewhere. Accounts with 2FA still need their second factor.
+func (s *Service) FinishMagicLink(ctx context.Context, req *api.MagicLinkLoginRequest, deviceNonce string, client ClientInfo) (*api.LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if deviceNonce == "" {
+		return nil, ErrInvalidMagicLink
+	}
+	consumed, err := s.consumeBoundActionToken(ctx, models.ActionMagicLogin, utils.HashToken(deviceNonce), req.Token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSignedToken) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, consumed.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrAccountDeleted
+	}
+
+	now := time.Now()
+	// other links of the user are useless now
+	if err := s.actionTokenRepo.InvalidateForUser(ctx, user.ID, models.ActionMagicLogin, now); err != nil {
+		log.Printf("作废旧的登录链接失败: %v", err)
+	}
+	// opening the link proves the user owns the address
+	if !user.EmailVerified {
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to verify email: %w", err)
+		}
+		user.EmailVerified, user.EmailVerifiedAt = true, &now
+	}
+
+	if user.TwoFactorEnabled {
+		log.Printf("登录链接验证通过, 等待两步验证: %s", user.Email)
+		return s.twoFactorChallenge(ctx, user)
+	}
+	resp, err := s.startSession(ctx, user, client, "magic_link")
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("登录链接登录成功: %s", user.Email)
+	return resp, nil
+}
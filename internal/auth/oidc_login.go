@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/config"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/oidc"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// OIDCProviders lists the identity providers users can sign in with
+func (s *Service) OIDCProviders() []string {
+	return s.oidc.Names()
+}
+
+// StartOIDCLogin returns the provider URL to redirect to and the flow state the
+// caller must keep in a cookie for the callback. State, nonce and PKCE
+// verifier are all derived from that signed value, so nothing is stored server-side.
+func (s *Service) StartOIDCLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, err := s.oidc.Get(providerName)
+	if err != nil {
+		return "", "", err
+	}
+	flowState, err := s.signer.Sign(oidcPurpose(providerName), uuid.Nil, s.oidcStateTTL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign oidc state: %w", err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx,
+		deriveOIDCValue("state", flowState),
+		deriveOIDCValue("nonce", flowState),
+		deriveOIDCValue("pkce", flowState))
+	if err != nil {
+		log.Printf("获取身份提供方配置失败(%s): %v", providerName, err)
+		return "", "", ErrOIDCProviderUnavailable
+	}
+	return authURL, flowState, nil
+}
+
+// FinishOIDCLogin handles the provider callback: it checks the state, redeems
+// the code and signs in the linked (or newly linked) user
+func (s *Service) FinishOIDCLogin(ctx context.Context, providerName string, flowState string, state string, code string, client ClientInfo) (*api.LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	provider, err := s.oidc.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.signer.Verify(oidcPurpose(providerName), flowState); err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(deriveOIDCValue("state", flowState))) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := provider.Exchange(ctx, code, deriveOIDCValue("pkce", flowState), deriveOIDCValue("nonce", flowState))
+	if err != nil {
+		log.Printf("OIDC 授权码交换失败(%s): %v", providerName, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	user, err := s.userForIdentity(ctx, providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("OIDC 登录成功(%s): %s", providerName, user.Email)
+	if user.TwoFactorEnabled {
+		return s.twoFactorChallenge(ctx, user)
+	}
+	return s.startSession(ctx, user, client, "oidc:"+providerName)
+}
+
+// userForIdentity resolves the local user of an external identity. Unknown
+// identities are linked to the verified account with the same, provider
+// verified, email, or get a new account.
+func (s *Service) userForIdentity(ctx context.Context, providerName string, claims *oidc.IDTokenClaims) (*models.User, error) {
+	now := time.Now()
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, providerName, claims.Subject)
+	if err == nil {
+		if err := s.identityRepo.TouchLogin(ctx, identity.ID, now); err != nil {
+			log.Printf("更新身份登录时间失败: %v", err)
+		}
+		return s.userRepo.GetByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, storage.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// linking by email is only safe when the provider vouches for the address
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	email := models.NormalizeEmail(claims.Email)
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	switch {
+	case err == nil:
+		// whoever registered an unverified account may not own the address and
+		// would keep their password, so the owner has to claim it first
+		if !user.EmailVerified {
+			return nil, ErrOIDCAccountUnverified
+		}
+	case errors.Is(err, storage.ErrRecordNotFound):
+		if user, err = s.createOIDCUser(ctx, providerName, email, claims.Name); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.identityRepo.Create(ctx, &models.UserIdentity{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Provider:    providerName,
+		Subject:     claims.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}); err != nil {
+		return nil, err
+	}
+	log.Printf("已关联外部身份(%s): %s", providerName, user.Email)
+	return user, nil
+}
+
+// createOIDCUser creates an account for a first time OIDC login. It gets a
+// random password, the user can set a real one through the reset flow. Unless
+// registration is open the login has no way to bring an invitation, so only
+// existing accounts can sign in with a provider.
+func (s *Service) createOIDCUser(ctx context.Context, providerName string, email string, name string) (*models.User, error) {
+	switch s.registrationMode {
+	case config.RegistrationClosed:
+		return nil, ErrRegistrationClosed
+	case config.RegistrationInvite:
+		return nil, ErrInvitationRequired
+	}
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.hashPassword.Hash(ctx, randomPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	now := time.Now()
+	user := &models.User{
+		ID:              uuid.New(),
+		Name:            name,
+		Email:           email,
+		Password:        hashedPassword,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+		InviteQuota:     s.defaultInviteQuota,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventUserRegistered, ActorID: user.ID, Metadata: map[string]interface{}{"method": "oidc:" + providerName}})
+	return user, nil
+}
+
+func oidcPurpose(providerName string) string {
+	return "oidc:" + providerName
+}
+
+// deriveOIDCValue derives one of the flow values from the signed flow state
+func deriveOIDCValue(label string, flowState string) string {
+	sum := sha256.Sum256([]byte(label + ":" + flowState))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
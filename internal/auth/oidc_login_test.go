@@ -0,0 +1,436 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/config"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/oidc"
+	"github.com/jinxinyu/go_backend/internal/oidc/oidctest"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// The fakes embed the repository interfaces: the OIDC login only needs a few
+// methods, any other call panics and shows up as a failing test.
+
+type fakeUserRepo struct {
+	storage.UserRepository
+	users map[uuid.UUID]*models.User
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, user *models.User) error {
+	user.Email = models.NormalizeEmail(user.Email)
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, storage.ErrRecordNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, user := range r.users {
+		if models.NormalizeEmail(user.Email) == models.NormalizeEmail(email) {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, storage.ErrRecordNotFound
+}
+
+type fakeIdentityRepo struct {
+	storage.IdentityRepository
+	identities []models.UserIdentity
+}
+
+func (r *fakeIdentityRepo) Create(ctx context.Context, identity *models.UserIdentity) error {
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *fakeIdentityRepo) GetByProviderSubject(ctx context.Context, provider string, subject string) (*models.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			found := identity
+			return &found, nil
+		}
+	}
+	return nil, storage.ErrRecordNotFound
+}
+
+func (r *fakeIdentityRepo) TouchLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return nil
+}
+
+type fakeSessionRepo struct {
+	storage.SessionRepository
+}
+
+func (r *fakeSessionRepo) Create(ctx context.Context, session *models.Session) error {
+	return nil
+}
+
+type fakeRefreshTokenRepo struct {
+	storage.RefreshTokenRepository
+}
+
+func (r *fakeRefreshTokenRepo) Create(ctx context.Context, token *models.RefreshToken) error {
+	return nil
+}
+
+type fakeRoleRepo struct {
+	storage.RoleRepository
+}
+
+func (r *fakeRoleRepo) ListForUser(ctx context.Context, userID uuid.UUID) ([]models.Role, error) {
+	return nil, nil
+}
+
+type fakeAuditRepo struct {
+	storage.AuditRepository
+}
+
+func (r *fakeAuditRepo) Append(ctx context.Context, event *models.AuditEvent) error {
+	return nil
+}
+
+// fakeHasher keeps the tests fast, argon2id is not what they are about
+type fakeHasher struct {
+	utils.HashedPassword
+}
+
+func (h fakeHasher) Hash(ctx context.Context, password string) (string, error) {
+	return "hash:" + password, nil
+}
+
+type oidcLoginTest struct {
+	service    *Service
+	server     *oidctest.Server
+	users      *fakeUserRepo
+	identities *fakeIdentityRepo
+}
+
+func newOIDCLoginTest(t *testing.T, registrationMode string) *oidcLoginTest {
+	t.Helper()
+	server, err := oidctest.NewServer("test-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{
+		JWTSecret:                   "test-jwt-secret",
+		JWTAlgorithm:                utils.AlgorithmHS256,
+		JWTExpirationMinutes:        5,
+		RefreshTokenExpirationHours: 1,
+		OIDCStateMinutes:            10,
+		RegistrationMode:            registrationMode,
+	}
+	tokenMaker, err := utils.NewTokenGenerator(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := utils.NewLinkSigner("test-link-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := &oidcLoginTest{
+		server:     server,
+		users:      &fakeUserRepo{users: map[uuid.UUID]*models.User{}},
+		identities: &fakeIdentityRepo{},
+	}
+	test.service = NewService(cfg, Dependencies{
+		UserRepo:         test.users,
+		IdentityRepo:     test.identities,
+		SessionRepo:      &fakeSessionRepo{},
+		RefreshTokenRepo: &fakeRefreshTokenRepo{},
+		RoleRepo:         &fakeRoleRepo{},
+		TokenMaker:       tokenMaker,
+		HashPassword:     fakeHasher{},
+		Signer:           signer,
+		OIDC:             oidc.NewRegistry([]config.OIDCProviderConfig{server.Config("mock")}, server.Client()),
+		Audit:            audit.NewRecorder(&fakeAuditRepo{}),
+	})
+	return test
+}
+
+// login runs the whole flow: start, authorize at the provider with claims, call back
+func (test *oidcLoginTest) login(t *testing.T, claims jwt.MapClaims) (*models.User, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, flowState, err := test.service.StartOIDCLogin(ctx, "mock")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	code, state, err := test.server.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := test.service.FinishOIDCLogin(ctx, "mock", flowState, state, code, ClientInfo{IP: "127.0.0.1"})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatal("login returned no tokens")
+	}
+	userID, err := uuid.Parse(resp.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return test.users.GetByID(ctx, userID)
+}
+
+func (test *oidcLoginTest) addUser(email string, verified bool) *models.User {
+	user := &models.User{ID: uuid.New(), Name: "local", Email: email, Password: "hash:local", EmailVerified: verified}
+	test.users.users[user.ID] = user
+	return user
+}
+
+func identityClaims(subject string, email string, verified bool) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":            subject,
+		"email":          email,
+		"email_verified": verified,
+		"name":           "Alice",
+	}
+}
+
+// withDefaults completes claims with issuer, audience and expiry of the server
+func (test *oidcLoginTest) withDefaults(claims jwt.MapClaims) jwt.MapClaims {
+	full := test.server.Claims("", "")
+	delete(full, "nonce")
+	for key, value := range claims {
+		full[key] = value
+	}
+	return full
+}
+
+func TestOIDCLoginCreatesUserOnFirstLogin(t *testing.T) {
+	test := newOIDCLoginTest(t, config.RegistrationOpen)
+
+	user, err := test.login(t, test.withDefaults(identityClaims("sub-1", "Alice@Example.com", true)))
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if user.Email != "alice@example.com" || !user.EmailVerified || user.Name != "Alice" {
+		t.Errorf("created user = %+v, want verified alice@example.com called Alice", user)
+	}
+	if len(test.identities.identities) != 1 || test.identities.identities[0].UserID != user.ID {
+		t.Fatalf("identities = %+v, want one linked to the new user", test.identities.identities)
+	}
+
+	again, err := test.login(t, test.withDefaults(identityClaims("sub-1", "alice@example.com", true)))
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != user.ID || len(test.users.users) != 1 || len(test.identities.identities) != 1 {
+		t.Error("second login of the same identity did not sign in the same user")
+	}
+}
+
+func TestOIDCLoginLinking(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           string
+		localEmail     string
+		localVerified  bool
+		claims         jwt.MapClaims
+		wantErr        error
+		wantLinkToUser bool
+	}{
+		{
+			name:           "links verified account with same email",
+			mode:           config.RegistrationOpen,
+			localEmail:     "alice@example.com",
+			localVerified:  true,
+			claims:         identityClaims("sub-1", "ALICE@example.com", true),
+			wantLinkToUser: true,
+		},
+		{
+			name:          "refuses unverified local account",
+			mode:          config.RegistrationOpen,
+			localEmail:    "alice@example.com",
+			localVerified: false,
+			claims:        identityClaims("sub-1", "alice@example.com", true),
+			wantErr:       ErrOIDCAccountUnverified,
+		},
+		{
+			name:          "refuses email the provider did not verify",
+			mode:          config.RegistrationOpen,
+			localEmail:    "alice@example.com",
+			localVerified: true,
+			claims:        identityClaims("sub-1", "alice@example.com", false),
+			wantErr:       ErrOIDCEmailNotVerified,
+		},
+		{
+			name:           "links existing account while registration is closed",
+			mode:           config.RegistrationClosed,
+			localEmail:     "alice@example.com",
+			localVerified:  true,
+			claims:         identityClaims("sub-1", "alice@example.com", true),
+			wantLinkToUser: true,
+		},
+		{
+			name:          "no new account while registration is closed",
+			mode:          config.RegistrationClosed,
+			localEmail:    "bob@example.com",
+			localVerified: true,
+			claims:        identityClaims("sub-1", "alice@example.com", true),
+			wantErr:       ErrRegistrationClosed,
+		},
+		{
+			name:          "no new account without invitation",
+			mode:          config.RegistrationInvite,
+			localEmail:    "bob@example.com",
+			localVerified: true,
+			claims:        identityClaims("sub-1", "alice@example.com", true),
+			wantErr:       ErrInvitationRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newOIDCLoginTest(t, tt.mode)
+			local := test.addUser(tt.localEmail, tt.localVerified)
+
+			user, err := test.login(t, test.withDefaults(tt.claims))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				if len(test.identities.identities) != 0 {
+					t.Errorf("identity was linked despite the error: %+v", test.identities.identities)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if tt.wantLinkToUser && user.ID != local.ID {
+				t.Errorf("signed in user %s, want the local account %s", user.ID, local.ID)
+			}
+			if user.Password != "hash:local" {
+				t.Error("linking changed the local password")
+			}
+		})
+	}
+}
+
+func TestFinishOIDCLoginRejectsForgedCallbacks(t *testing.T) {
+	ctx := context.Background()
+	client := ClientInfo{IP: "127.0.0.1"}
+
+	tests := []struct {
+		name    string
+		finish  func(test *oidcLoginTest) error
+		wantErr error
+	}{
+		{
+			name: "state mismatch",
+			finish: func(test *oidcLoginTest) error {
+				authURL, flowState, err := test.service.StartOIDCLogin(ctx, "mock")
+				if err != nil {
+					return err
+				}
+				code, _, err := test.server.Authorize(authURL, test.withDefaults(identityClaims("sub-1", "alice@example.com", true)))
+				if err != nil {
+					return err
+				}
+				return ignoreResponse(test.service.FinishOIDCLogin(ctx, "mock", flowState, "forged-state", code, client))
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name: "tampered flow state",
+			finish: func(test *oidcLoginTest) error {
+				authURL, flowState, err := test.service.StartOIDCLogin(ctx, "mock")
+				if err != nil {
+					return err
+				}
+				code, state, err := test.server.Authorize(authURL, test.withDefaults(identityClaims("sub-1", "alice@example.com", true)))
+				if err != nil {
+					return err
+				}
+				return ignoreResponse(test.service.FinishOIDCLogin(ctx, "mock", flowState+"x", state, code, client))
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			name: "flow state of another provider",
+			finish: func(test *oidcLoginTest) error {
+				flowState, err := test.service.signer.Sign(oidcPurpose("other"), uuid.Nil, time.Minute)
+				if err != nil {
+					return err
+				}
+				return ignoreResponse(test.service.FinishOIDCLogin(ctx, "mock", flowState, deriveOIDCValue("state", flowState), "code-1", client))
+			},
+			wantErr: ErrInvalidOIDCState,
+		},
+		{
+			// a code obtained in one flow must not be redeemable in another,
+			// the PKCE verifier of the victim's flow does not match it
+			name: "pkce mismatch",
+			finish: func(test *oidcLoginTest) error {
+				attackerURL, _, err := test.service.StartOIDCLogin(ctx, "mock")
+				if err != nil {
+					return err
+				}
+				code, _, err := test.server.Authorize(attackerURL, test.withDefaults(identityClaims("attacker", "attacker@example.com", true)))
+				if err != nil {
+					return err
+				}
+				_, victimFlow, err := test.service.StartOIDCLogin(ctx, "mock")
+				if err != nil {
+					return err
+				}
+				return ignoreResponse(test.service.FinishOIDCLogin(ctx, "mock", victimFlow, deriveOIDCValue("state", victimFlow), code, client))
+			},
+			wantErr: ErrOIDCLoginFailed,
+		},
+		{
+			name: "nonce mismatch",
+			finish: func(test *oidcLoginTest) error {
+				claims := test.withDefaults(identityClaims("sub-1", "alice@example.com", true))
+				claims["nonce"] = "replayed-nonce"
+				_, err := test.login(t, claims)
+				return err
+			},
+			wantErr: ErrOIDCLoginFailed,
+		},
+		{
+			name: "unknown provider",
+			finish: func(test *oidcLoginTest) error {
+				return ignoreResponse(test.service.FinishOIDCLogin(ctx, "other", "flow", "state", "code-1", client))
+			},
+			wantErr: oidc.ErrUnknownProvider,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newOIDCLoginTest(t, config.RegistrationOpen)
+			if err := tt.finish(test); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if len(test.users.users) != 0 || len(test.identities.identities) != 0 {
+				t.Error("a rejected callback created a user or an identity")
+			}
+		})
+	}
+}
+
+func ignoreResponse(_ interface{}, err error) error {
+	return err
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// ForgotPassword emails a password reset link. It behaves the same whether or
+// not the account exists: nothing is reported back and the email is sent in
+// the background so the response time does not depend on it either.
+func (s *Service) ForgotPassword(ctx context.Context, req *api.ForgotPasswordRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if user.DeletedAt != nil {
+		log.Printf("账户已删除, 忽略密码重置请求: %s", user.Email)
+		return nil
+	}
+
+	// silently drop requests that come too fast instead of answering 429,
+	// which would tell the caller the account exists
+	recent, err := s.actionTokenRepo.CountCreatedSince(ctx, user.ID, models.ActionPasswordReset, time.Now().Add(-s.resendCooldown))
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		log.Printf("密码重置请求过于频繁, 已忽略: %s", user.Email)
+		return nil
+	}
+
+	token, err := s.createActionToken(ctx, user.ID, models.ActionPasswordReset, s.passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	link := s.appLink("/reset-password", token)
+
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.email.SendPasswordReset(sendCtx, user.Email, user.Name, link, s.passwordResetTTL); err != nil {
+			log.Printf("发送密码重置邮件失败: %v", err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword sets a new password using a reset link and signs the user out everywhere
+func (s *Service) ResetPassword(ctx context.Context, req *api.ResetPasswordRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// check the new password before consuming the link, so a rejected
+	// password can be corrected without requesting a new email
+	payload, err := s.signer.Verify(models.ActionPasswordReset, req.Token)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	user, err := s.userRepo.GetByID(ctx, payload.Subject)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if err := s.passwordPolicy.Validate(ctx, req.Password, user.Email, user.Name); err != nil {
+		return err
+	}
+
+	consumed, err := s.consumeActionToken(ctx, models.ActionPasswordReset, req.Token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSignedToken) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	hashedPassword, err := s.hashPassword.Hash(ctx, req.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, consumed.UserID, hashedPassword); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.actionTokenRepo.InvalidateForUser(ctx, consumed.UserID, models.ActionPasswordReset, now); err != nil {
+		log.Printf("作废旧的重置链接失败: %v", err)
+	}
+	if err := s.revokeAllUserTokens(ctx, consumed.UserID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventPasswordReset, ActorID: consumed.UserID})
+	log.Printf("密码已重置, 用户ID: %s", consumed.UserID)
+	return nil
+}
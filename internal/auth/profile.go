@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// GetProfile returns the account of the user together with their roles
+func (s *Service) GetProfile(ctx context.Context, userID uuid.UUID) (*api.UserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	return s.profileResponse(ctx, user)
+}
+
+// UpdateProfile changes the name and time zone of the user
+func (s *Service) UpdateProfile(ctx context.Context, userID uuid.UUID, req *api.UpdateProfileRequest) (*api.UserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, ErrInvalidProfileName
+		}
+		user.Name = name
+	}
+	if req.TimeZone != nil {
+		zone := strings.TrimSpace(*req.TimeZone)
+		location, err := time.LoadLocation(zone)
+		// LoadLocation maps "" to UTC and accepts "Local", neither is a zone of the user
+		if err != nil || zone == "" || location == time.Local {
+			return nil, ErrInvalidTimeZone.WithDetail("%q", *req.TimeZone)
+		}
+		user.TimeZone = location.String()
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, user.ID, user.Name, user.TimeZone); err != nil {
+		return nil, err
+	}
+	return s.profileResponse(ctx, user)
+}
+
+// ChangePassword sets a new password after checking the current one. Every
+// other session of the user is signed out, the calling one stays logged in.
+func (s *Service) ChangePassword(ctx context.Context, claims *utils.Claims, req *api.ChangePasswordRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+	match, err := s.hashPassword.Compare(ctx, req.CurrentPassword, user.Password)
+	if err != nil {
+		return fmt.Errorf("failed to compare password: %w", err)
+	}
+	if !match {
+		return ErrInvalidCredentials
+	}
+	if err := s.passwordPolicy.Validate(ctx, req.NewPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hashPassword.Hash(ctx, req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	now := time.Now()
+	// pending reset links were asked for the old password
+	if err := s.actionTokenRepo.InvalidateForUser(ctx, user.ID, models.ActionPasswordReset, now); err != nil {
+		log.Printf("作废旧的重置链接失败: %v", err)
+	}
+	revoked, err := s.revokeOtherSessions(ctx, user.ID, claims.SessionID, now)
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventPasswordChanged, ActorID: user.ID, Metadata: map[string]interface{}{"revokedSessions": revoked}})
+	log.Printf("密码已修改, 用户ID: %s, 撤销其他会话: %d", user.ID, revoked)
+	return nil
+}
+
+// ChangeEmail starts switching the account to a new address. The address only
+// replaces the current one once the link sent to it is opened.
+func (s *Service) ChangeEmail(ctx context.Context, userID uuid.UUID, req *api.ChangeEmailRequest) (*api.UserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	match, err := s.hashPassword.Compare(ctx, req.Password, user.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare password: %w", err)
+	}
+	if !match {
+		return nil, ErrInvalidCredentials
+	}
+
+	newEmail := models.NormalizeEmail(req.Email)
+	if newEmail == models.NormalizeEmail(user.Email) {
+		return nil, ErrEmailUnchanged
+	}
+	// when taken addresses are hidden, a taken one gets the same answer and its
+	// owner is told about the attempt instead of receiving a link
+	owner, err := s.emailOwner(ctx, newEmail)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil && !s.hideRegisteredEmails {
+		return nil, ErrEmailExists
+	}
+
+	// only the link of the latest request may confirm the pending address
+	if err := s.actionTokenRepo.InvalidateForUser(ctx, user.ID, models.ActionChangeEmail, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetPendingEmail(ctx, user.ID, newEmail); err != nil {
+		return nil, err
+	}
+	user.PendingEmail = newEmail
+
+	var send func(ctx context.Context) error
+	if owner != nil {
+		s.audit.Record(ctx, audit.Event{Type: audit.EventEmailChangeTaken, ActorID: user.ID, SubjectID: owner.ID})
+		send = func(ctx context.Context) error {
+			if owner.DeletedAt != nil {
+				return nil
+			}
+			return s.email.SendEmailChangeAttempt(ctx, owner.Email, owner.Name)
+		}
+	} else {
+		token, err := s.createActionToken(ctx, user.ID, models.ActionChangeEmail, s.verificationTTL)
+		if err != nil {
+			return nil, err
+		}
+		link := s.appLink("/confirm-email", token)
+		send = func(ctx context.Context) error {
+			return s.email.SendEmailChange(ctx, newEmail, user.Name, link, s.verificationTTL)
+		}
+	}
+	if s.hideRegisteredEmails {
+		// sent in the background either way, so the response time does not
+		// tell a taken address apart
+		go func() {
+			sendCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := send(sendCtx); err != nil {
+				log.Printf("发送邮箱变更邮件失败: %v", err)
+			}
+		}()
+	} else if err := send(ctx); err != nil {
+		return nil, fmt.Errorf("failed to send email change confirmation: %w", err)
+	}
+	if err := s.email.SendEmailChangeNotice(ctx, user.Email, user.Name, newEmail); err != nil {
+		log.Printf("发送邮箱变更提醒失败: %v", err)
+	}
+
+	log.Printf("邮箱变更待确认, 用户ID: %s", user.ID)
+	return s.profileResponse(ctx, user)
+}
+
+// ConfirmEmailChange consumes the link sent to the new address and makes it
+// the email of the account
+func (s *Service) ConfirmEmailChange(ctx context.Context, req *api.ConfirmEmailChangeRequest) (*api.UserResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	consumed, err := s.consumeActionToken(ctx, models.ActionChangeEmail, req.Token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSignedToken) {
+			return nil, ErrInvalidEmailChangeToken
+		}
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, consumed.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidEmailChangeToken
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if user.PendingEmail == "" {
+		return nil, ErrInvalidEmailChangeToken
+	}
+	// the address may have been registered since the change was requested
+	if err := s.checkEmailAvailable(ctx, user.PendingEmail); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	oldEmail := user.Email
+	if err := s.userRepo.UpdateEmail(ctx, user.ID, user.PendingEmail, now); err != nil {
+		return nil, err
+	}
+	user.Email, user.PendingEmail = user.PendingEmail, ""
+	user.EmailVerified, user.EmailVerifiedAt = true, &now
+
+	// links sent to the old address are useless now
+	if err := s.actionTokenRepo.InvalidateForUser(ctx, user.ID, models.ActionVerifyEmail, now); err != nil {
+		log.Printf("作废旧的验证链接失败: %v", err)
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventEmailChanged, ActorID: user.ID, Metadata: map[string]interface{}{
+		"from": oldEmail,
+		"to":   user.Email,
+	}})
+	log.Printf("邮箱已变更, 用户ID: %s, %s -> %s", user.ID, oldEmail, user.Email)
+	return s.profileResponse(ctx, user)
+}
+
+// checkEmailAvailable returns ErrEmailExists when another account uses email
+func (s *Service) checkEmailAvailable(ctx context.Context, email string) error {
+	owner, err := s.emailOwner(ctx, email)
+	if err != nil {
+		return err
+	}
+	if owner != nil {
+		return ErrEmailExists
+	}
+	return nil
+}
+
+// emailOwner returns the account using email, nil when there is none
+func (s *Service) emailOwner(ctx context.Context, email string) (*models.User, error) {
+	owner, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to check existing user: %w", err)
+	}
+	return owner, nil
+}
+
+func (s *Service) profileResponse(ctx context.Context, user *models.User) (*api.UserResponse, error) {
+	roles, _, err := s.userRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	resp := toUserResponse(user)
+	resp.Roles = roles
+	return &resp, nil
+}
@@ -2,14 +2,92 @@ package auth
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jinxinyu/go_backend/internal/middleware"
+	"github.com/jinxinyu/go_backend/internal/utils"
 )
 
-func RegisterUserRoutes(router *gin.RouterGroup, service *Service) {
+// RegisterUserRoutes registers the public auth routes on router and the ones
+// requiring a logged in user on protected
+func RegisterUserRoutes(router *gin.RouterGroup, protected *gin.RouterGroup, service *Service) {
 	handler := NewHandler(service)
 
 	authRoutes := router.Group("/auth")
 	{
 		authRoutes.POST("/register", handler.RegisterUser)
 		authRoutes.POST("/login", handler.LoginUser)
+		authRoutes.POST("/login/2fa", handler.CompleteTwoFactorLogin)
+		authRoutes.POST("/magic-link", handler.StartMagicLink)
+		authRoutes.POST("/magic-link/verify", handler.FinishMagicLink)
+		authRoutes.POST("/refresh", handler.RefreshToken)
+		authRoutes.POST("/verify", handler.VerifyEmail)
+		authRoutes.POST("/verify/resend", handler.ResendVerification)
+		authRoutes.POST("/password/forgot", handler.ForgotPassword)
+		authRoutes.POST("/password/reset", handler.ResetPassword)
+		// opened from the email, the link itself proves the request
+		authRoutes.POST("/email/confirm", handler.ConfirmEmailChange)
+		authRoutes.POST("/account/restore", handler.RestoreAccount)
+		authRoutes.GET("/oidc/providers", handler.ListOIDCProviders)
+		authRoutes.GET("/oidc/:provider/login", handler.StartOIDCLogin)
+		authRoutes.GET("/oidc/:provider/callback", handler.FinishOIDCLogin)
+	}
+
+	protectedAuthRoutes := protected.Group("/auth")
+	{
+		protectedAuthRoutes.POST("/logout", handler.Logout)
+		protectedAuthRoutes.POST("/2fa/enroll", handler.EnrollTwoFactor)
+		protectedAuthRoutes.POST("/2fa/confirm", handler.ConfirmTwoFactor)
+		protectedAuthRoutes.POST("/2fa/disable", handler.DisableTwoFactor)
+	}
+
+	profileRoutes := protected.Group("/me")
+	{
+		profileRoutes.GET("", handler.GetProfile)
+		profileRoutes.PATCH("", handler.UpdateProfile)
+		profileRoutes.POST("/password", handler.ChangePassword)
+		profileRoutes.POST("/email", handler.ChangeEmail)
+		profileRoutes.GET("/export", handler.ExportAccount)
+		profileRoutes.POST("/delete", handler.DeleteAccount)
+	}
+
+	// personal access tokens are managed with a session only, a leaked token
+	// cannot mint or revoke others
+	tokenRoutes := protected.Group("/me/tokens")
+	{
+		tokenRoutes.POST("", handler.CreatePersonalToken)
+		tokenRoutes.GET("", handler.ListPersonalTokens)
+		tokenRoutes.DELETE("/:id", handler.RevokePersonalToken)
+	}
+
+	invitationRoutes := protected.Group("/me/invitations")
+	{
+		invitationRoutes.POST("", handler.CreateInvitation)
+		invitationRoutes.GET("", handler.ListMyInvitations)
+		invitationRoutes.DELETE("/:id", handler.RevokeInvitation)
+	}
+
+	sessionRoutes := protected.Group("/me/sessions")
+	{
+		sessionRoutes.GET("", handler.ListSessions)
+		sessionRoutes.POST("/revoke-others", handler.RevokeOtherSessions)
+		sessionRoutes.DELETE("/:id", handler.RevokeSession)
+	}
+}
+
+// RegisterAdminRoutes registers the user management routes, protected must
+// already require a logged in user
+func RegisterAdminRoutes(protected *gin.RouterGroup, service *Service) {
+	handler := NewHandler(service)
+
+	adminRoutes := protected.Group("/admin")
+	{
+		adminRoutes.GET("/users", middleware.RequirePermission(utils.PermissionUsersRead), handler.ListUsers)
+		adminRoutes.GET("/users/:id", middleware.RequirePermission(utils.PermissionUsersRead), handler.GetUser)
+		adminRoutes.PUT("/users/:id/roles", middleware.RequirePermission(utils.PermissionUsersWrite), handler.SetUserRoles)
+		adminRoutes.POST("/users/:id/sign-out", middleware.RequirePermission(utils.PermissionUsersWrite), handler.SignOutUser)
+		adminRoutes.PUT("/users/:id/invite-quota", middleware.RequirePermission(utils.PermissionUsersWrite), handler.SetInviteQuota)
+		adminRoutes.GET("/roles", middleware.RequirePermission(utils.PermissionUsersRead), handler.ListRoles)
+		adminRoutes.GET("/invitations", middleware.RequirePermission(utils.PermissionInvitationsRead), handler.ListInvitations)
+		adminRoutes.POST("/invitations", middleware.RequirePermission(utils.PermissionInvitationsWrite), handler.AdminCreateInvitation)
+		adminRoutes.DELETE("/invitations/:id", middleware.RequirePermission(utils.PermissionInvitationsWrite), handler.AdminRevokeInvitation)
 	}
 }
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+const (
+	maxUserAgentLength = 512
+	// last seen is recorded with this precision to spare a write per request
+	sessionTouchInterval = time.Minute
+)
+
+// startSession records a new login and issues its first token pair, the
+// session ID doubles as the refresh token family. Deleted accounts cannot
+// log in, whatever the login method; method is recorded in the audit log.
+func (s *Service) startSession(ctx context.Context, user *models.User, client ClientInfo, method string) (*api.LoginResponse, error) {
+	if user.DeletedAt != nil {
+		return nil, ErrAccountDeleted
+	}
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		UserAgent:  truncate(client.UserAgent, maxUserAgentLength),
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.refreshTokenTTL),
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventLoginSucceeded, ActorID: user.ID, Metadata: map[string]interface{}{
+		"method":    method,
+		"sessionId": session.ID,
+	}})
+	return s.issueTokens(ctx, user, session.ID)
+}
+
+// extendSession records a token refresh on the session of the refresh token family
+func (s *Service) extendSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, client ClientInfo, now time.Time) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if errors.Is(err, storage.ErrRecordNotFound) {
+		// refresh token families from before sessions were tracked get one now
+		return s.sessionRepo.Create(ctx, &models.Session{
+			ID:         sessionID,
+			UserID:     userID,
+			UserAgent:  truncate(client.UserAgent, maxUserAgentLength),
+			IP:         client.IP,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(s.refreshTokenTTL),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		return ErrInvalidRefreshToken
+	}
+	return s.sessionRepo.Extend(ctx, session.ID, client.IP, truncate(client.UserAgent, maxUserAgentLength), now, now.Add(s.refreshTokenTTL))
+}
+
+// checkSession rejects access tokens of revoked sessions and keeps last seen up to date
+func (s *Service) checkSession(ctx context.Context, claims *utils.Claims) error {
+	session, err := s.sessionRepo.GetByID(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+	if session.RevokedAt != nil || session.UserID != claims.UserID {
+		return ErrSessionRevoked
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := s.sessionRepo.TouchLastSeen(ctx, session.ID, now); err != nil {
+			// not worth failing the request over
+			log.Printf("更新会话活跃时间失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// endSession revokes a session of the user together with its refresh tokens
+func (s *Service) endSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, now time.Time) error {
+	if err := s.sessionRepo.Revoke(ctx, userID, sessionID, now); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, sessionID, now); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the active sessions of the user, the one of the caller is flagged as current
+func (s *Service) ListSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) ([]api.SessionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	sessions, err := s.sessionRepo.ListActiveByUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]api.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, api.SessionResponse{
+			ID:         session.ID.String(),
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+	return resp, nil
+}
+
+// RevokeSession signs out one session of the user, its tokens stop working immediately
+func (s *Service) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := s.endSession(ctx, userID, sessionID, time.Now()); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventSessionRevoked, ActorID: userID, Metadata: map[string]interface{}{"sessionId": sessionID}})
+	log.Printf("会话已撤销, 用户ID: %s, 会话: %s", userID, sessionID)
+	return nil
+}
+
+// RevokeOtherSessions signs out every session of the user except the current one
+func (s *Service) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	revoked, err := s.revokeOtherSessions(ctx, userID, currentSessionID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	s.audit.Record(ctx, audit.Event{Type: audit.EventTokensRevoked, ActorID: userID, Metadata: map[string]interface{}{
+		"reason":   "other_sessions",
+		"sessions": revoked,
+	}})
+	log.Printf("其他会话已撤销, 用户ID: %s, 数量: %d", userID, revoked)
+	return revoked, nil
+}
+
+// revokeOtherSessions ends every session of the user but except together with their refresh tokens
+func (s *Service) revokeOtherSessions(ctx context.Context, userID uuid.UUID, except uuid.UUID, now time.Time) (int, error) {
+	revoked, err := s.sessionRepo.RevokeAllForUser(ctx, userID, except, now)
+	if err != nil {
+		return 0, err
+	}
+	for _, sessionID := range revoked {
+		if err := s.refreshTokenRepo.RevokeFamily(ctx, sessionID, now); err != nil {
+			return 0, fmt.Errorf("failed to revoke refresh token family: %w", err)
+		}
+	}
+	return len(revoked), nil
+}
+
+func truncate(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+	// do not cut a multi-byte character in half
+	for maxLength > 0 && !utf8.RuneStart(value[maxLength]) {
+		maxLength--
+	}
+	return value[:maxLength]
+}
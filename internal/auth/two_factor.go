@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+const (
+	recoveryCodeCount = 10
+	// accept codes of the previous and next 30s window to absorb clock drift
+	totpSkew = 1
+)
+
+// EnrollTwoFactor generates a new TOTP secret for the user. 2FA stays off
+// until ConfirmTwoFactor receives a valid code for this secret.
+func (s *Service) EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (*api.TwoFactorEnrollResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateTwoFactor(ctx, user.ID, secret, false); err != nil {
+		return nil, err
+	}
+
+	return &api.TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.twoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor turns 2FA on once the user proves the authenticator works,
+// and returns the recovery codes. They are only shown this one time.
+func (s *Service) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, req *api.TwoFactorConfirmRequest) (*api.TwoFactorConfirmResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	step, ok := utils.ValidateTOTP(user.TwoFactorSecret, strings.TrimSpace(req.Code), time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	// like checkSecondFactor, so the confirmation code cannot be replayed at login
+	fresh, err := s.userRepo.AdvanceTwoFactorStep(ctx, user.ID, step)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recoveryCodeRepo.ReplaceForUser(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateTwoFactor(ctx, user.ID, user.TwoFactorSecret, true); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventTwoFactorEnabled, ActorID: user.ID})
+	log.Printf("两步验证已开启: %s", user.Email)
+	return &api.TwoFactorConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor turns 2FA off, it requires both the password and a current code
+func (s *Service) DisableTwoFactor(ctx context.Context, userID uuid.UUID, req *api.TwoFactorDisableRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnrolled
+	}
+
+	match, err := s.hashPassword.Compare(ctx, req.Password, user.Password)
+	if err != nil {
+		return fmt.Errorf("failed to compare password: %w", err)
+	}
+	if !match {
+		return ErrInvalidCredentials
+	}
+	if err := s.checkSecondFactor(ctx, user, req.Code); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateTwoFactor(ctx, user.ID, "", false); err != nil {
+		return err
+	}
+	if err := s.recoveryCodeRepo.DeleteForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, audit.Event{Type: audit.EventTwoFactorDisabled, ActorID: user.ID})
+	log.Printf("两步验证已关闭: %s", user.Email)
+	return nil
+}
+
+// CompleteTwoFactorLogin exchanges the challenge from LoginUser and a second
+// factor for the real tokens
+func (s *Service) CompleteTwoFactorLogin(ctx context.Context, req *api.TwoFactorLoginRequest, client ClientInfo) (*api.LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	payload, err := s.signer.Verify(models.ActionTwoFactorLogin, req.ChallengeToken)
+	if err != nil {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+	user, err := s.userRepo.GetByID(ctx, payload.Subject)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidTwoFactorChallenge
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+
+	// a wrong code keeps the challenge usable, so only a successful attempt consumes
+	// it; guessing is bounded by the same throttling as passwords
+	if err := s.loginGuard.check(ctx, user.Email, client.IP); err != nil {
+		return nil, err
+	}
+	if err := s.checkSecondFactor(ctx, user, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.recordLoginFailure(ctx, user.Email, client, user)
+		}
+		return nil, err
+	}
+	if err := s.loginGuard.recordSuccess(ctx, user.Email); err != nil {
+		log.Printf("重置登录失败计数失败: %v", err)
+	}
+	if _, err := s.actionTokenRepo.Consume(ctx, models.ActionTwoFactorLogin, utils.HashToken(req.ChallengeToken), time.Now()); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidTwoFactorChallenge
+		}
+		return nil, err
+	}
+
+	log.Printf("两步验证通过: %s", user.Email)
+	return s.startSession(ctx, user, client, "two_factor")
+}
+
+// twoFactorChallenge answers the password step of a login for a 2FA account
+func (s *Service) twoFactorChallenge(ctx context.Context, user *models.User) (*api.LoginResponse, error) {
+	challenge, err := s.createActionToken(ctx, user.ID, models.ActionTwoFactorLogin, s.twoFactorChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &api.LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+}
+
+// checkSecondFactor accepts a TOTP code that was not used before, or an unused recovery code
+func (s *Service) checkSecondFactor(ctx context.Context, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if step, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, time.Now(), totpSkew); ok {
+		fresh, err := s.userRepo.AdvanceTwoFactorStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := s.recoveryCodeRepo.Consume(ctx, user.ID, utils.HashToken(normalizeRecoveryCode(code)), time.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	log.Printf("使用了恢复码登录: %s", user.Email)
+	return nil
+}
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx and their hashes
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		secret, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(secret[:10])
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, utils.HashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets users type the code with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/audit"
+	"github.com/jinxinyu/go_backend/internal/config"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// UpdateTwoFactor and AdvanceTwoFactorStep follow the contract of the real repository
+
+func (r *fakeUserRepo) UpdateTwoFactor(ctx context.Context, id uuid.UUID, secret string, enabled bool) error {
+	user, ok := r.users[id]
+	if !ok {
+		return storage.ErrRecordNotFound
+	}
+	user.TwoFactorSecret = secret
+	user.TwoFactorEnabled = enabled
+	if !enabled {
+		user.TwoFactorLastStep = 0
+	}
+	return nil
+}
+
+func (r *fakeUserRepo) AdvanceTwoFactorStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	user, ok := r.users[id]
+	if !ok || user.TwoFactorLastStep >= step {
+		return false, nil
+	}
+	user.TwoFactorLastStep = step
+	return true, nil
+}
+
+type fakeRecoveryCodeRepo struct {
+	storage.RecoveryCodeRepository
+}
+
+func (r *fakeRecoveryCodeRepo) ReplaceForUser(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return nil
+}
+
+func (r *fakeRecoveryCodeRepo) Consume(ctx context.Context, userID uuid.UUID, codeHash string, usedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+type fakeActionTokenRepo struct {
+	storage.ActionTokenRepository
+	tokens map[string]*models.ActionToken
+}
+
+func (r *fakeActionTokenRepo) Create(ctx context.Context, token *models.ActionToken) error {
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *fakeActionTokenRepo) Consume(ctx context.Context, purpose string, tokenHash string, now time.Time) (*models.ActionToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil {
+		return nil, storage.ErrRecordNotFound
+	}
+	token.UsedAt = &now
+	found := *token
+	return &found, nil
+}
+
+type fakeLoginAttempts struct {
+	storage.LoginAttemptStore
+}
+
+func (s *fakeLoginAttempts) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	return nil, storage.ErrRecordNotFound
+}
+
+func (s *fakeLoginAttempts) RegisterFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	return &models.LoginAttempt{Key: key, Failures: 1, LastFailureAt: now}, nil
+}
+
+func (s *fakeLoginAttempts) Reset(ctx context.Context, key string) error {
+	return nil
+}
+
+func newTwoFactorTest(t *testing.T) (*Service, *fakeUserRepo) {
+	t.Helper()
+	cfg := &config.Config{
+		JWTSecret:                   "test-jwt-secret",
+		JWTAlgorithm:                utils.AlgorithmHS256,
+		JWTExpirationMinutes:        5,
+		RefreshTokenExpirationHours: 1,
+		TwoFactorChallengeMinutes:   5,
+	}
+	tokenMaker, err := utils.NewTokenGenerator(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := utils.NewLinkSigner("test-link-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &fakeUserRepo{users: map[uuid.UUID]*models.User{}}
+	service := NewService(cfg, Dependencies{
+		UserRepo:         users,
+		ActionTokenRepo:  &fakeActionTokenRepo{tokens: map[string]*models.ActionToken{}},
+		RecoveryCodeRepo: &fakeRecoveryCodeRepo{},
+		LoginAttempts:    &fakeLoginAttempts{},
+		SessionRepo:      &fakeSessionRepo{},
+		RefreshTokenRepo: &fakeRefreshTokenRepo{},
+		RoleRepo:         &fakeRoleRepo{},
+		TokenMaker:       tokenMaker,
+		HashPassword:     fakeHasher{},
+		Signer:           signer,
+		Audit:            audit.NewRecorder(&fakeAuditRepo{}),
+	})
+	return service, users
+}
+
+// currentTOTP computes the RFC 6238 code of secret like an authenticator app would
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestConfirmationCodeCannotBeReplayedAtLogin(t *testing.T) {
+	ctx := context.Background()
+	service, users := newTwoFactorTest(t)
+	user := &models.User{ID: uuid.New(), Name: "local", Email: "alice@example.com", Password: "hash:local", EmailVerified: true}
+	users.users[user.ID] = user
+
+	enrolled, err := service.EnrollTwoFactor(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("EnrollTwoFactor: %v", err)
+	}
+	code := currentTOTP(t, enrolled.Secret)
+	if _, err := service.ConfirmTwoFactor(ctx, user.ID, &api.TwoFactorConfirmRequest{Code: code}); err != nil {
+		t.Fatalf("ConfirmTwoFactor: %v", err)
+	}
+	if !users.users[user.ID].TwoFactorEnabled {
+		t.Fatal("2FA is not enabled after confirmation")
+	}
+
+	confirmed, err := users.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := service.twoFactorChallenge(ctx, confirmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.CompleteTwoFactorLogin(ctx, &api.TwoFactorLoginRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		Code:           code,
+	}, ClientInfo{IP: "127.0.0.1"})
+	if !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("login reusing the confirmation code: got %v, want %v", err, ErrInvalidTwoFactorCode)
+	}
+}
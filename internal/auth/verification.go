@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// VerifyEmail consumes an email verification link and marks the account as verified
+func (s *Service) VerifyEmail(ctx context.Context, req *api.VerifyEmailRequest) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	consumed, err := s.consumeActionToken(ctx, models.ActionVerifyEmail, req.Token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSignedToken) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.userRepo.MarkEmailVerified(ctx, consumed.UserID, now); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+	// older links of the same user are useless now
+	if err := s.actionTokenRepo.InvalidateForUser(ctx, consumed.UserID, models.ActionVerifyEmail, now); err != nil {
+		log.Printf("作废旧的验证链接失败: %v", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, consumed.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	log.Printf("邮箱验证成功: %s", user.Email)
+	return user, nil
+}
+
+// ResendVerification sends a new verification link. Unknown and already
+// verified addresses are ignored so the endpoint does not reveal accounts.
+func (s *Service) ResendVerification(ctx context.Context, req *api.ResendVerificationRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if user.EmailVerified || user.DeletedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	recent, err := s.actionTokenRepo.CountCreatedSince(ctx, user.ID, models.ActionVerifyEmail, now.Add(-s.resendCooldown))
+	if err != nil {
+		return err
+	}
+	hourly, err := s.actionTokenRepo.CountCreatedSince(ctx, user.ID, models.ActionVerifyEmail, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	// like ForgotPassword, drop requests that come too fast instead of
+	// answering 429, which only unverified accounts could get
+	if recent > 0 || hourly >= int64(s.resendMaxPerHour) {
+		log.Printf("验证邮件请求过于频繁, 已忽略: %s", user.Email)
+		return nil
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *Service) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.createActionToken(ctx, user.ID, models.ActionVerifyEmail, s.verificationTTL)
+	if err != nil {
+		return err
+	}
+	link := s.appLink("/verify-email", token)
+	if err := s.email.SendVerification(ctx, user.Email, user.Name, link, s.verificationTTL); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+// createActionToken signs a single use token for the user and stores its hash
+func (s *Service) createActionToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	return s.createBoundActionToken(ctx, userID, purpose, "", ttl)
+}
+
+// createBoundActionToken works like createActionToken, the token can only be
+// consumed again with the same binding, e.g. a value kept by the client
+func (s *Service) createBoundActionToken(ctx context.Context, userID uuid.UUID, purpose string, binding string, ttl time.Duration) (string, error) {
+	token, err := s.signer.Sign(signingPurpose(purpose, binding), userID, ttl)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	if err := s.actionTokenRepo.Create(ctx, &models.ActionToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, nil
+}
+
+// consumeActionToken checks the signature of a token and marks it as used.
+// utils.ErrInvalidSignedToken is returned for any token that cannot be used.
+func (s *Service) consumeActionToken(ctx context.Context, purpose string, token string) (*models.ActionToken, error) {
+	return s.consumeBoundActionToken(ctx, purpose, "", token)
+}
+
+// consumeBoundActionToken consumes a token of createBoundActionToken
+func (s *Service) consumeBoundActionToken(ctx context.Context, purpose string, binding string, token string) (*models.ActionToken, error) {
+	payload, err := s.signer.Verify(signingPurpose(purpose, binding), token)
+	if err != nil {
+		return nil, err
+	}
+	consumed, err := s.actionTokenRepo.Consume(ctx, purpose, utils.HashToken(token), time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, utils.ErrInvalidSignedToken
+		}
+		return nil, err
+	}
+	if consumed.UserID != payload.Subject {
+		return nil, utils.ErrInvalidSignedToken
+	}
+	return consumed, nil
+}
+
+// signingPurpose folds the binding of a token into the purpose it is signed for
+func signingPurpose(purpose string, binding string) string {
+	if binding == "" {
+		return purpose
+	}
+	return purpose + ":" + binding
+}
+
+// appLink builds a frontend link carrying token as query parameter
+func (s *Service) appLink(path string, token string) string {
+	return s.appBaseURL + path + "?token=" + url.QueryEscape(token)
+}
@@ -1,11 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
+// defaultJWTSecret is the placeholder JWT_SECRET used when none is configured.
+const defaultJWTSecret = "your-secret-key"
+
 type Config struct {
 
 	//Environment Config
@@ -27,16 +32,121 @@ type Config struct {
 	//JWT Config
 	JWTSecret            string `mapstructure:"JWT_SECRET"`
 	JWTExpirationMinutes int    `mapstructure:"JWT_EXPIRATION_MINUTES"`
+	JWTAlgorithm         string `mapstructure:"JWT_ALGORITHM"`         //HS256, RS256 or EdDSA
+	JWTPrivateKeyFile    string `mapstructure:"JWT_PRIVATE_KEY_FILE"`  //PEM signing key for RS256/EdDSA
+	JWTKeyID             string `mapstructure:"JWT_KEY_ID"`            //kid header, defaults to the key thumbprint
+	JWTVerificationKeys  string `mapstructure:"JWT_VERIFICATION_KEYS"` //previous public keys during rotation, "kid=path,kid=path"
+
+	//Refresh Token Config
+	RefreshTokenExpirationHours int `mapstructure:"REFRESH_TOKEN_EXPIRATION_HOURS"`
+
+	//Token Revocation Config, the store is "postgres" or "memory"
+	RevocationStore                string `mapstructure:"REVOCATION_STORE"`
+	RevocationPruneIntervalMinutes int    `mapstructure:"REVOCATION_PRUNE_INTERVAL_MINUTES"`
 
 	//Email Config
 	EmailProvider string `mapstructure:"EMAIL_PROVIDER"`
 	EmailAPIKey   string `mapstructure:"EMAIL_API_KEY"`
 	EmailSender   string `mapstructure:"EMAIL_SENDER"`
 
+	//Email Verification Config
+	AppBaseURL                       string `mapstructure:"APP_BASE_URL"` //frontend url used to build the links in emails
+	LinkSigningSecret                string `mapstructure:"LINK_SIGNING_SECRET"`
+	EmailVerificationExpirationHours int    `mapstructure:"EMAIL_VERIFICATION_EXPIRATION_HOURS"`
+	EmailVerificationRequired        bool   `mapstructure:"EMAIL_VERIFICATION_REQUIRED"` //block login of unverified accounts
+	EmailResendCooldownSeconds       int    `mapstructure:"EMAIL_RESEND_COOLDOWN_SECONDS"`
+	EmailResendMaxPerHour            int    `mapstructure:"EMAIL_RESEND_MAX_PER_HOUR"`
+	PasswordResetExpirationMinutes   int    `mapstructure:"PASSWORD_RESET_EXPIRATION_MINUTES"`
+
+	//Login Brute-Force Protection Config, the store is "postgres" or "memory"
+	LoginAttemptStore         string `mapstructure:"LOGIN_ATTEMPT_STORE"`
+	LoginFreeAttempts         int    `mapstructure:"LOGIN_FREE_ATTEMPTS"`    //failures per email before backoff starts
+	LoginIPFreeAttempts       int    `mapstructure:"LOGIN_IP_FREE_ATTEMPTS"` //failures per client IP before backoff starts
+	LoginBackoffBaseSeconds   int    `mapstructure:"LOGIN_BACKOFF_BASE_SECONDS"`
+	LoginBackoffMaxSeconds    int    `mapstructure:"LOGIN_BACKOFF_MAX_SECONDS"`
+	LoginLockoutThreshold     int    `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"` //0 disables the account lockout
+	LoginLockoutMinutes       int    `mapstructure:"LOGIN_LOCKOUT_MINUTES"`
+	LoginAttemptWindowMinutes int    `mapstructure:"LOGIN_ATTEMPT_WINDOW_MINUTES"`
+
+	//Two-Factor Config
+	TwoFactorIssuer           string `mapstructure:"TWO_FACTOR_ISSUER"` //name shown in authenticator apps
+	TwoFactorChallengeMinutes int    `mapstructure:"TWO_FACTOR_CHALLENGE_MINUTES"`
+
+	//Password Hashing Config (argon2id). With ARGON2_TUNE_TARGET_MS set, the
+	//iterations are raised at startup until one hash takes about that long
+	Argon2MemoryKiB    uint32 `mapstructure:"ARGON2_MEMORY_KIB"`
+	Argon2Iterations   uint32 `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism  uint8  `mapstructure:"ARGON2_PARALLELISM"`
+	Argon2SaltLength   uint32 `mapstructure:"ARGON2_SALT_LENGTH"`
+	Argon2KeyLength    uint32 `mapstructure:"ARGON2_KEY_LENGTH"`
+	Argon2TuneTargetMS int    `mapstructure:"ARGON2_TUNE_TARGET_MS"` //0 disables the startup benchmark
+	//every hash allocates ARGON2_MEMORY_KIB, at most PASSWORD_HASH_WORKERS run at
+	//once and requests waiting longer than the queue timeout get a 503
+	PasswordHashWorkers        int `mapstructure:"PASSWORD_HASH_WORKERS"`
+	PasswordHashQueueTimeoutMS int `mapstructure:"PASSWORD_HASH_QUEUE_TIMEOUT_MS"`
+
+	//Password Policy Config, the score is 0-4 like zxcvbn and the breached list
+	//holds SHA-1 hashes, one per line. An empty list file disables that check.
+	PasswordMinLength           int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength           int    `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordMinCharacterClasses int    `mapstructure:"PASSWORD_MIN_CHARACTER_CLASSES"` //of lowercase, uppercase, digits and symbols
+	PasswordMinScore            int    `mapstructure:"PASSWORD_MIN_SCORE"`
+	PasswordBreachedListFile    string `mapstructure:"PASSWORD_BREACHED_LIST_FILE"`
+
+	//Admin Config, users with these comma separated emails get the admin role at
+	//startup once they have registered
+	AdminEmails string `mapstructure:"ADMIN_EMAILS"`
+
+	//OpenID Connect Config, OIDC_PROVIDERS is a comma separated list of names.
+	//Each provider is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+	//OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and OIDC_<NAME>_SCOPES
+	OIDCProviderNames string               `mapstructure:"OIDC_PROVIDERS"`
+	OIDCStateMinutes  int                  `mapstructure:"OIDC_STATE_MINUTES"`
+	OIDCProviders     []OIDCProviderConfig `mapstructure:"-"`
+
+	//Registration Config, the mode is "open", "invite" (an invitation code is
+	//required) or "closed". With existing emails hidden registering a taken
+	//address answers like a successful sign-up and emails the owner instead
+	RegistrationMode               string `mapstructure:"REGISTRATION_MODE"`
+	RegistrationHideExistingEmails bool   `mapstructure:"REGISTRATION_HIDE_EXISTING_EMAILS"`
+	InvitationExpirationDays       int    `mapstructure:"INVITATION_EXPIRATION_DAYS"`
+	InviteDefaultQuota             int    `mapstructure:"INVITE_DEFAULT_QUOTA"` //people a new user may invite
+
+	//Text Statistics Config, how Han characters, Latin words, numbers and
+	//punctuation add up to the word count of a writing log
+	TextStatsCountPunctuation bool `mapstructure:"TEXTSTATS_COUNT_PUNCTUATION"`
+	TextStatsCountNumbers     bool `mapstructure:"TEXTSTATS_COUNT_NUMBERS"`
+	TextStatsJoinHyphenated   bool `mapstructure:"TEXTSTATS_JOIN_HYPHENATED"` //"well-known" is one word
+
+	//Magic Link Config, passwordless login links sent by email
+	MagicLinkExpirationMinutes int `mapstructure:"MAGIC_LINK_EXPIRATION_MINUTES"`
+
+	//Account Deletion Config, deleted accounts can be restored during the grace
+	//period and are purged for good afterwards
+	AccountDeletionGraceDays    int `mapstructure:"ACCOUNT_DELETION_GRACE_DAYS"`
+	AccountPurgeIntervalMinutes int `mapstructure:"ACCOUNT_PURGE_INTERVAL_MINUTES"`
+
 	//Whether the environment is Production,and the default is "-"
 	IsProduction bool `mapstructure:"-"`
 }
 
+// Registration modes
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite"
+	RegistrationClosed = "closed"
+)
+
+// OIDCProviderConfig configures one OpenID Connect identity provider
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
 func LoadConfig(path string) (config *Config, errr error) {
 	config = &Config{}
 
@@ -52,8 +162,61 @@ func LoadConfig(path string) (config *Config, errr error) {
 	viper.SetDefault("DB_MAX_IDLE_CONNS", 10)
 	viper.SetDefault("DB_MAX_OPEN_CONNS", 100)
 	viper.SetDefault("DB_CONN_MAX_LIFETIME_MINUTES", 100)
-	viper.SetDefault("JWT_SECRET", "your-secret-key")
+	viper.SetDefault("JWT_SECRET", defaultJWTSecret)
 	viper.SetDefault("JWT_EXPIRATION_MINUTES", 60)
+	viper.SetDefault("JWT_ALGORITHM", "HS256")
+	viper.SetDefault("JWT_PRIVATE_KEY_FILE", "")
+	viper.SetDefault("JWT_KEY_ID", "")
+	viper.SetDefault("JWT_VERIFICATION_KEYS", "")
+	viper.SetDefault("REFRESH_TOKEN_EXPIRATION_HOURS", 24*30)
+	viper.SetDefault("REVOCATION_STORE", "postgres")
+	viper.SetDefault("REVOCATION_PRUNE_INTERVAL_MINUTES", 10)
+	viper.SetDefault("EMAIL_PROVIDER", "log")
+	viper.SetDefault("EMAIL_API_KEY", "")
+	viper.SetDefault("EMAIL_SENDER", "")
+	viper.SetDefault("APP_BASE_URL", "http://localhost:3000")
+	viper.SetDefault("LINK_SIGNING_SECRET", "")
+	viper.SetDefault("EMAIL_VERIFICATION_EXPIRATION_HOURS", 24)
+	viper.SetDefault("EMAIL_VERIFICATION_REQUIRED", false)
+	viper.SetDefault("EMAIL_RESEND_COOLDOWN_SECONDS", 60)
+	viper.SetDefault("EMAIL_RESEND_MAX_PER_HOUR", 5)
+	viper.SetDefault("PASSWORD_RESET_EXPIRATION_MINUTES", 30)
+	viper.SetDefault("LOGIN_ATTEMPT_STORE", "postgres")
+	viper.SetDefault("LOGIN_FREE_ATTEMPTS", 3)
+	viper.SetDefault("LOGIN_IP_FREE_ATTEMPTS", 20)
+	viper.SetDefault("LOGIN_BACKOFF_BASE_SECONDS", 1)
+	viper.SetDefault("LOGIN_BACKOFF_MAX_SECONDS", 300)
+	viper.SetDefault("LOGIN_LOCKOUT_THRESHOLD", 10)
+	viper.SetDefault("LOGIN_LOCKOUT_MINUTES", 15)
+	viper.SetDefault("LOGIN_ATTEMPT_WINDOW_MINUTES", 60)
+	viper.SetDefault("TWO_FACTOR_ISSUER", "Write")
+	viper.SetDefault("TWO_FACTOR_CHALLENGE_MINUTES", 5)
+	viper.SetDefault("ARGON2_MEMORY_KIB", 64*1024)
+	viper.SetDefault("ARGON2_ITERATIONS", 1)
+	viper.SetDefault("ARGON2_PARALLELISM", 2)
+	viper.SetDefault("ARGON2_SALT_LENGTH", 16)
+	viper.SetDefault("ARGON2_KEY_LENGTH", 32)
+	viper.SetDefault("ARGON2_TUNE_TARGET_MS", 0)
+	viper.SetDefault("PASSWORD_HASH_WORKERS", 4)
+	viper.SetDefault("PASSWORD_HASH_QUEUE_TIMEOUT_MS", 3000)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_MAX_LENGTH", 128)
+	viper.SetDefault("PASSWORD_MIN_CHARACTER_CLASSES", 2)
+	viper.SetDefault("PASSWORD_MIN_SCORE", 2)
+	viper.SetDefault("PASSWORD_BREACHED_LIST_FILE", "")
+	viper.SetDefault("ADMIN_EMAILS", "")
+	viper.SetDefault("OIDC_PROVIDERS", "")
+	viper.SetDefault("OIDC_STATE_MINUTES", 10)
+	viper.SetDefault("REGISTRATION_MODE", RegistrationOpen)
+	viper.SetDefault("REGISTRATION_HIDE_EXISTING_EMAILS", false)
+	viper.SetDefault("INVITATION_EXPIRATION_DAYS", 14)
+	viper.SetDefault("INVITE_DEFAULT_QUOTA", 0)
+	viper.SetDefault("TEXTSTATS_COUNT_PUNCTUATION", false)
+	viper.SetDefault("TEXTSTATS_COUNT_NUMBERS", true)
+	viper.SetDefault("TEXTSTATS_JOIN_HYPHENATED", true)
+	viper.SetDefault("MAGIC_LINK_EXPIRATION_MINUTES", 15)
+	viper.SetDefault("ACCOUNT_DELETION_GRACE_DAYS", 30)
+	viper.SetDefault("ACCOUNT_PURGE_INTERVAL_MINUTES", 60)
 
 	viper.AddConfigPath(path)
 	viper.SetConfigName(".env")
@@ -79,6 +242,53 @@ func LoadConfig(path string) (config *Config, errr error) {
 	}
 
 	config.IsProduction = config.Environment == "production"
+	// only the HS256 secret is private enough to double as the link secret
+	if config.LinkSigningSecret == "" && (config.JWTAlgorithm == "" || config.JWTAlgorithm == "HS256") {
+		config.LinkSigningSecret = config.JWTSecret
+	}
+	if config.LinkSigningSecret == "" || config.LinkSigningSecret == defaultJWTSecret {
+		return nil, fmt.Errorf("LINK_SIGNING_SECRET (or JWT_SECRET with HS256) must be set to a non-default value")
+	}
+
+	config.RegistrationMode = strings.ToLower(strings.TrimSpace(config.RegistrationMode))
+	switch config.RegistrationMode {
+	case RegistrationOpen, RegistrationInvite, RegistrationClosed:
+	default:
+		return nil, fmt.Errorf("REGISTRATION_MODE must be %q, %q or %q, got %q", RegistrationOpen, RegistrationInvite, RegistrationClosed, config.RegistrationMode)
+	}
+
+	config.OIDCProviders, err = loadOIDCProviders(config.OIDCProviderNames)
+	if err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
+
+// loadOIDCProviders reads the OIDC_<NAME>_* settings of every listed provider
+func loadOIDCProviders(names string) ([]OIDCProviderConfig, error) {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(viper.GetString(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
@@ -0,0 +1,123 @@
+// Package email sends transactional emails (verification links, notices...)
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/config"
+)
+
+// Message is a single outgoing email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers a message through an email provider
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewSender returns the Sender configured by EMAIL_PROVIDER.
+// "log" (or an empty provider) only prints the emails, which is handy in development.
+func NewSender(cfg *config.Config) (Sender, error) {
+	switch strings.ToLower(cfg.EmailProvider) {
+	case "", "log":
+		return &logSender{}, nil
+	case "sendgrid":
+		if cfg.EmailAPIKey == "" {
+			return nil, fmt.Errorf("EMAIL_API_KEY is not set")
+		}
+		if cfg.EmailSender == "" {
+			return nil, fmt.Errorf("EMAIL_SENDER is not set")
+		}
+		return &sendgridSender{
+			apiKey: cfg.EmailAPIKey,
+			from:   cfg.EmailSender,
+			client: &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported email provider: %s", cfg.EmailProvider)
+	}
+}
+
+// logSender writes emails to the log instead of sending them
+type logSender struct{}
+
+func (s *logSender) Send(ctx context.Context, msg *Message) error {
+	log.Printf("[email] to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+const sendgridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+type sendgridSender struct {
+	apiKey string
+	from   string
+	client *http.Client
+}
+
+type sendgridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendgridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendgridPersonalization struct {
+	To []sendgridAddress `json:"to"`
+}
+
+type sendgridRequest struct {
+	Personalizations []sendgridPersonalization `json:"personalizations"`
+	From             sendgridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendgridContent         `json:"content"`
+}
+
+func (s *sendgridSender) Send(ctx context.Context, msg *Message) error {
+	payload := sendgridRequest{
+		Personalizations: []sendgridPersonalization{{To: []sendgridAddress{{Email: msg.To}}}},
+		From:             sendgridAddress{Email: s.from},
+		Subject:          msg.Subject,
+		Content:          []sendgridContent{{Type: "text/plain", Value: msg.Text}},
+	}
+	if msg.HTML != "" {
+		payload.Content = append(payload.Content, sendgridContent{Type: "text/html", Value: msg.HTML})
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendgridEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build email request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("email provider returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Service renders the application emails and hands them to a Sender
+type Service struct {
+	sender  Sender
+	appName string
+}
+
+func NewService(sender Sender) *Service {
+	return &Service{sender: sender, appName: "Write"}
+}
+
+// SendVerification sends the link confirming that the user owns the address
+func (s *Service) SendVerification(ctx context.Context, to string, name string, link string, validFor time.Duration) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Verify your %s account", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link is valid for %s and can only be used once.\nIf you did not create an account, you can ignore this email.\n",
+			name, link, formatDuration(validFor)),
+	})
+}
+
+// SendPasswordReset sends the link allowing the user to choose a new password
+func (s *Service) SendPasswordReset(ctx context.Context, to string, name string, link string, validFor time.Duration) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Reset your %s password", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\n"+
+			"The link is valid for %s and can only be used once.\nIf you did not ask for this, you can ignore this email, your password stays unchanged.\n",
+			name, link, formatDuration(validFor)),
+	})
+}
+
+// SendMagicLink sends a link signing the user in without a password
+func (s *Service) SendMagicLink(ctx context.Context, to string, name string, link string, validFor time.Duration) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Your %s sign-in link", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nOpen the link below to sign in:\n\n%s\n\n"+
+			"The link is valid for %s, can only be used once and only works in the browser where you asked for it.\n"+
+			"If you did not ask for this, you can ignore this email.\n",
+			name, link, formatDuration(validFor)),
+	})
+}
+
+// SendEmailChange sends the link confirming the new address of an email change
+func (s *Service) SendEmailChange(ctx context.Context, to string, name string, link string, validFor time.Duration) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Confirm your new %s email address", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nPlease confirm that you want to use this address for your account by opening the link below:\n\n%s\n\n"+
+			"The link is valid for %s and can only be used once.\nIf you did not ask for this, you can ignore this email.\n",
+			name, link, formatDuration(validFor)),
+	})
+}
+
+// SendEmailChangeNotice tells the current address that a change to newEmail was requested
+func (s *Service) SendEmailChangeNotice(ctx context.Context, to string, name string, newEmail string) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Your %s email address is being changed", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone signed in to your account asked to change its email address to %s. "+
+			"The change takes effect once the new address is confirmed.\n\n"+
+			"If this was not you, change your password and sign out your other sessions.\n",
+			name, newEmail),
+	})
+}
+
+// SendEmailChangeAttempt tells the owner of an address that another account
+// asked to switch to it, nothing changes for either account
+func (s *Service) SendEmailChangeAttempt(ctx context.Context, to string, name string) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("This address already belongs to your %s account", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone asked to use this email address for another %s account, but it already belongs to yours. "+
+			"The request was refused and your account is unchanged.\n\nIf it was you, sign in to this account instead, you can ignore this email otherwise.\n",
+			name, s.appName),
+	})
+}
+
+// SendAccountDeletion confirms a deletion request and carries the link restoring the account
+func (s *Service) SendAccountDeletion(ctx context.Context, to string, name string, link string, purgeAt time.Time) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Your %s account will be deleted", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nYour account was deleted as requested. It and all your writing will be erased for good on %s.\n\n"+
+			"Changed your mind? Open the link below before then to restore the account:\n\n%s\n",
+			name, purgeAt.UTC().Format("2006-01-02 15:04 MST"), link),
+	})
+}
+
+// SendRegistrationAttempt tells the owner of an address that someone tried to
+// sign up with it, with a link to reset the password in case they forgot it
+func (s *Service) SendRegistrationAttempt(ctx context.Context, to string, name string, loginLink string, resetLink string, validFor time.Duration) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("You already have a %s account", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone tried to create a new account with this email address, but you already have one. "+
+			"If it was you, sign in here:\n\n%s\n\nForgot your password? Choose a new one with the link below:\n\n%s\n\n"+
+			"The link is valid for %s and can only be used once.\nIf it was not you, you can ignore this email, your account is unchanged.\n",
+			name, loginLink, resetLink, formatDuration(validFor)),
+	})
+}
+
+// SendAccountLocked warns the user that repeated failed logins locked the account
+func (s *Service) SendAccountLocked(ctx context.Context, to string, name string, until time.Time) error {
+	return s.sender.Send(ctx, &Message{
+		To:      to,
+		Subject: fmt.Sprintf("Your %s account was temporarily locked", s.appName),
+		Text: fmt.Sprintf("Hi %s,\n\nWe noticed several failed sign-in attempts on your account, so it is locked until %s.\n\n"+
+			"If this was you, just wait and try again. If it was not, consider resetting your password.\n",
+			name, until.UTC().Format("2006-01-02 15:04 MST")),
+	})
+}
+
+func formatDuration(d time.Duration) string {
+	value, unit := int(d/time.Minute), "minute"
+	if d >= time.Hour && d%time.Hour == 0 {
+		value, unit = int(d/time.Hour), "hour"
+	}
+	if value != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", value, unit)
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/apperror"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// context key under which the validated claims are stored
+const claimsContextKey = "auth.claims"
+
+var (
+	errMissingToken         = apperror.Unauthorized("missing_token", "missing or malformed authorization header")
+	errPersonalTokenRefused = apperror.Forbidden("personal_token_not_allowed", "personal access tokens cannot be used for this endpoint")
+	errInsufficientScope    = apperror.Forbidden("insufficient_scope", "token is missing the required scope")
+	errMissingPermission    = apperror.Forbidden("missing_permission", "permission denied")
+)
+
+// Authenticator turns a bearer token into the claims of the caller. It accepts
+// session JWTs as well as personal access tokens.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*utils.Claims, error)
+}
+
+// AuthMiddleware validates the "Authorization: Bearer <token>" header and
+// stores the resulting claims in the gin context. Requests without a valid
+// token are aborted with 401, the response is written by ErrorHandler. Personal access tokens are refused with 403,
+// routes reachable with them are registered behind ScopedAuthMiddleware.
+func AuthMiddleware(authenticator Authenticator) gin.HandlerFunc {
+	return authenticate(authenticator, false)
+}
+
+// ScopedAuthMiddleware works like AuthMiddleware but also accepts personal
+// access tokens. Every route behind it must declare the scope it needs with
+// RequireScope.
+func ScopedAuthMiddleware(authenticator Authenticator) gin.HandlerFunc {
+	return authenticate(authenticator, true)
+}
+
+func authenticate(authenticator Authenticator, allowPersonalTokens bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			abortWithError(c, errMissingToken)
+			return
+		}
+
+		claims, err := authenticator.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if claims.PersonalToken && !allowPersonalTokens {
+			abortWithError(c, errPersonalTokenRefused)
+			return
+		}
+
+		SetClaims(c, claims)
+		c.Next()
+	}
+}
+
+// RequireScope aborts with 403 when the caller authenticated with a personal
+// access token that was not granted scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			abortWithError(c, apperror.ErrUnauthorized)
+			return
+		}
+		if !claims.HasScope(scope) {
+			abortWithError(c, errInsufficientScope.WithDetail("%s", scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission aborts with 403 unless one of the caller's roles grants permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		if !ok {
+			abortWithError(c, apperror.ErrUnauthorized)
+			return
+		}
+		if !claims.HasPermission(permission) {
+			abortWithError(c, errMissingPermission.WithDetail("%s", permission))
+			return
+		}
+		c.Next()
+	}
+}
+
+// bearerToken extracts the token from an Authorization header value
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// SetClaims stores the claims of the authenticated caller in the context
+func SetClaims(c *gin.Context, claims *utils.Claims) {
+	c.Set(claimsContextKey, claims)
+}
+
+// GetClaims returns the claims of the authenticated caller, if any
+func GetClaims(c *gin.Context) (*utils.Claims, bool) {
+	value, exists := c.Get(claimsContextKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*utils.Claims)
+	return claims, ok && claims != nil
+}
+
+// GetUserID returns the ID of the authenticated caller, if any
+func GetUserID(c *gin.Context) (uuid.UUID, bool) {
+	claims, ok := GetClaims(c)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return claims.UserID, true
+}
+
+// GetEmail returns the email of the authenticated caller, if any
+func GetEmail(c *gin.Context) (string, bool) {
+	claims, ok := GetClaims(c)
+	if !ok {
+		return "", false
+	}
+	return claims.Email, true
+}
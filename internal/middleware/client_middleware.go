@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinxinyu/go_backend/internal/audit"
+)
+
+// ClientContext stores the IP and user agent of the caller in the request
+// context, so services can attach them to audit events
+func ClientContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := audit.WithClient(c.Request.Context(), c.ClientIP(), c.Request.UserAgent())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/apperror"
+)
+
+// errorBody is the envelope of every error response:
+// {"error": {"code": "...", "message": "...", "fields": [...]}}
+type errorBody struct {
+	Code       string                `json:"code"`
+	Message    string                `json:"message"`
+	Fields     []apperror.FieldError `json:"fields,omitempty"`
+	RetryAfter int                   `json:"retryAfter,omitempty"` //seconds
+}
+
+// ErrorHandler writes the last error handlers attached with c.Error. Errors
+// from the apperror package are answered with their status and code, anything
+// else is logged and answered with a generic 500.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		appErr := apperror.From(err)
+		if appErr.Kind == apperror.KindInternal {
+			log.Printf("请求处理失败 %s %s: %v", c.Request.Method, c.FullPath(), err)
+		} else {
+			log.Printf("请求被拒绝 %s %s: %v", c.Request.Method, c.FullPath(), err)
+		}
+
+		body := errorBody{Code: appErr.Code, Message: appErr.Message, Fields: appErr.Fields}
+		if appErr.RetryAfter > 0 {
+			body.RetryAfter = int(math.Ceil(appErr.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(body.RetryAfter))
+		}
+		c.JSON(appErr.Status(), gin.H{"error": body})
+	}
+}
+
+// abortWithError stops the chain, ErrorHandler writes the response
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// ParamID parses the uuid path parameter name, on failure the error is
+// recorded for ErrorHandler and false is returned
+func ParamID(c *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		_ = c.Error(apperror.ErrInvalidID.WithDetail("%q", c.Param(name)))
+		return uuid.Nil, false
+	}
+	return id, true
+}
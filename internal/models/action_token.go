@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of the single use tokens sent to users by email
+const (
+	ActionVerifyEmail   = "verify_email"
+	ActionPasswordReset = "password_reset"
+	// ActionChangeEmail confirms the new address of an email change
+	ActionChangeEmail = "change_email"
+	// ActionRestoreAccount cancels the deletion of an account during the grace period
+	ActionRestoreAccount = "restore_account"
+	// ActionMagicLogin is a passwordless login link, bound to the device that asked for it
+	ActionMagicLogin = "magic_login"
+	// ActionTwoFactorLogin is the challenge issued after the password step of a 2FA login
+	ActionTwoFactorLogin = "two_factor_login"
+)
+
+// ActionToken is a single use token sent to a user, e.g. in an email
+// verification link. Only the hash of the token is stored.
+type ActionToken struct {
+	ID        uuid.UUID  `gorm:"primary_key" json:"id"`
+	UserID    uuid.UUID  `gorm:"index;not null" json:"userId"`
+	Purpose   string     `gorm:"type:varchar(32);index;not null" json:"purpose"`
+	TokenHash string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
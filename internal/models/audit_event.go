@@ -0,0 +1,45 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent is one entry of the append-only security audit log. Every row
+// carries the hash of the previous one, so editing or deleting a row breaks
+// the chain from there on.
+type AuditEvent struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedAt time.Time  `gorm:"not null;index" json:"createdAt"`
+	EventType string     `gorm:"type:varchar(64);not null;index" json:"eventType"`
+	ActorID   *uuid.UUID `gorm:"type:uuid;index" json:"actorId,omitempty"`   //user performing the action, nil when unknown
+	SubjectID *uuid.UUID `gorm:"type:uuid;index" json:"subjectId,omitempty"` //user the action applies to, when not the actor
+	IP        string     `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent string     `gorm:"type:varchar(512)" json:"userAgent"`
+	// Metadata is a JSON object, kept as text so the hashed bytes survive a round trip
+	Metadata string `gorm:"type:text;not null" json:"metadata"`
+	// varchar, a char column pads the empty PrevHash of the first event with spaces
+	PrevHash string `gorm:"type:varchar(64);not null" json:"prevHash"`
+	Hash     string `gorm:"type:varchar(64);not null;uniqueIndex" json:"hash"`
+}
+
+// ComputeHash returns the hash sealing the event and its link to PrevHash
+func (e *AuditEvent) ComputeHash() string {
+	// a struct keeps the field order, and so the encoding, stable
+	content, _ := json.Marshal(struct {
+		PrevHash  string     `json:"prev"`
+		CreatedAt string     `json:"at"`
+		EventType string     `json:"type"`
+		ActorID   *uuid.UUID `json:"actor"`
+		SubjectID *uuid.UUID `json:"subject"`
+		IP        string     `json:"ip"`
+		UserAgent string     `json:"ua"`
+		Metadata  string     `json:"meta"`
+	}{e.PrevHash, e.CreatedAt.UTC().Format(time.RFC3339Nano), e.EventType, e.ActorID, e.SubjectID, e.IP, e.UserAgent, e.Metadata})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation lets up to MaxUses people register while registration is invite
+// only. The code is kept in clear so the inviter can share it again.
+type Invitation struct {
+	ID        uuid.UUID  `gorm:"primary_key" json:"id"`
+	Code      string     `gorm:"type:varchar(32);not null;uniqueIndex" json:"code"`
+	InviterID *uuid.UUID `gorm:"index" json:"inviterId,omitempty"` //nil once the inviter was purged
+	MaxUses   int        `gorm:"not null" json:"maxUses"`
+	Uses      int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+
+	// AdminIssued invitations were created in the admin console, they do not
+	// count against the invite quota of their inviter
+	AdminIssued bool `gorm:"not null;default:false" json:"adminIssued"`
+}
+
+// Usable reports whether the invitation can still be redeemed at now
+func (i *Invitation) Usable(now time.Time) bool {
+	return i.RevokedAt == nil && i.Uses < i.MaxUses && now.Before(i.ExpiresAt)
+}
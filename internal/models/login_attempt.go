@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for a key, which is either an
+// email ("email:...") or a client IP ("ip:...")
+type LoginAttempt struct {
+	Key           string     `gorm:"column:attempt_key;primary_key;type:varchar(320)" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessToken is a long-lived token a user creates for scripts and
+// integrations. Only the hash of the token is stored, Prefix keeps the first
+// characters so the user can tell their tokens apart.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `gorm:"primary_key" json:"id"`
+	UserID     uuid.UUID  `gorm:"index;not null" json:"userId"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Scopes     string     `gorm:"type:varchar(255);not null" json:"scopes"` //space separated
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	TokenHash  string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokedToken blocks a single access token (by its jti) until it would have expired anyway
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key;type:varchar(64)" json:"jti"`
+	UserID    uuid.UUID `gorm:"index;not null" json:"userId"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expiresAt"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// UserTokenRevocation blocks every access token of a user issued up to RevokedBefore.
+// The row can be dropped once ExpiresAt has passed, because by then all such tokens are expired.
+type UserTokenRevocation struct {
+	UserID        uuid.UUID `gorm:"primary_key" json:"userId"`
+	RevokedBefore time.Time `gorm:"not null" json:"revokedBefore"`
+	ExpiresAt     time.Time `gorm:"index;not null" json:"expiresAt"`
+}
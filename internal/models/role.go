@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role groups permissions that can be granted to users
+type Role struct {
+	ID          uuid.UUID `gorm:"primary_key" json:"id"`
+	Name        string    `gorm:"type:varchar(50);not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	Permissions string    `gorm:"type:varchar(1024);not null" json:"permissions"` //space separated
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// UserRole grants a role to a user
+type UserRole struct {
+	UserID    uuid.UUID  `gorm:"primaryKey" json:"userId"`
+	RoleID    uuid.UUID  `gorm:"primaryKey;index" json:"roleId"`
+	GrantedBy *uuid.UUID `json:"grantedBy,omitempty"` //nil when granted from config or the command line
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
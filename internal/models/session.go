@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one login of a user on a device. Its ID is also the FamilyID of
+// the refresh tokens and the "sid" claim of the access tokens issued for it.
+type Session struct {
+	ID         uuid.UUID  `gorm:"primary_key" json:"id"`
+	UserID     uuid.UUID  `gorm:"index;not null" json:"userId"`
+	UserAgent  string     `gorm:"type:varchar(512)" json:"userAgent"`
+	IP         string     `gorm:"type:varchar(64)" json:"ip"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	LastSeenAt time.Time  `gorm:"not null" json:"lastSeenAt"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,4 +14,33 @@ type User struct {
 	Password  string    `gorm:"not null" json:"-"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	EmailVerified   bool       `gorm:"not null;default:false" json:"emailVerified"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	// PendingEmail is the address the user asked to switch to, set until the new address is confirmed
+	PendingEmail string `gorm:"type:varchar(255)" json:"-"`
+
+	// TimeZone is an IANA zone name, used to group writing statistics by the user's days
+	TimeZone string `gorm:"type:varchar(64);not null;default:'UTC'" json:"timeZone"`
+
+	// TwoFactorSecret is set at enrollment, TwoFactorEnabled only once a code was confirmed
+	TwoFactorEnabled  bool   `gorm:"not null;default:false" json:"twoFactorEnabled"`
+	TwoFactorSecret   string `gorm:"type:varchar(64)" json:"-"`
+	TwoFactorLastStep int64  `gorm:"not null;default:0" json:"-"` //last accepted TOTP time step, blocks code replay
+
+	// InvitedByID and InvitationID record the invitation the user registered
+	// with. InviteQuota is how many people the user may invite.
+	InvitedByID  *uuid.UUID `gorm:"index" json:"invitedById,omitempty"`
+	InvitationID *uuid.UUID `json:"-"`
+	InviteQuota  int        `gorm:"not null;default:0" json:"inviteQuota"`
+
+	// DeletedAt is set when the user deletes the account. It is a plain column,
+	// not gorm.DeletedAt, so the account can still be loaded and restored until
+	// it is purged.
+	DeletedAt *time.Time `gorm:"index" json:"deletedAt,omitempty"`
+}
+
+// NormalizeEmail is the form every email is stored and looked up in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
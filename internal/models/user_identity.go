@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links a user to an account at an external identity provider
+type UserIdentity struct {
+	ID          uuid.UUID  `gorm:"primary_key" json:"id"`
+	UserID      uuid.UUID  `gorm:"index;not null" json:"userId"`
+	Provider    string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	Email       string     `gorm:"type:varchar(255)" json:"email"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
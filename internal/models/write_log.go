@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/textstats"
 )
 
 type WriteLog struct {
@@ -14,4 +15,25 @@ type WriteLog struct {
 	Content    string    `gorm:"type:text;not null" json:"content,omitempty"`
 	CreatedAt  time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	// counted from Content by the server, WordsCount is their total
+	HanCount         int `gorm:"not null;default:0" json:"hanCount"`
+	KanaHangulCount  int `gorm:"not null;default:0" json:"kanaHangulCount"`
+	LatinWordCount   int `gorm:"not null;default:0" json:"latinWordCount"`
+	NumberCount      int `gorm:"not null;default:0" json:"numberCount"`
+	PunctuationCount int `gorm:"not null;default:0" json:"punctuationCount"`
+	ParagraphCount   int `gorm:"not null;default:0" json:"paragraphCount"`
+	CharacterCount   int `gorm:"not null;default:0" json:"characterCount"`
+}
+
+// SetStats stores the statistics counted from Content
+func (l *WriteLog) SetStats(stats textstats.Stats) {
+	l.WordsCount = stats.Words
+	l.HanCount = stats.HanCharacters
+	l.KanaHangulCount = stats.KanaHangul
+	l.LatinWordCount = stats.LatinWords
+	l.NumberCount = stats.Numbers
+	l.PunctuationCount = stats.Punctuation
+	l.ParagraphCount = stats.Paragraphs
+	l.CharacterCount = stats.Characters
 }
@@ -0,0 +1,189 @@
+// Package oidctest runs a local OpenID Connect provider for tests. It serves
+// discovery, a key set and a token endpoint that checks PKCE, and signs the ID
+// tokens it hands out with its own RSA key.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jinxinyu/go_backend/internal/config"
+)
+
+// Server is a mock identity provider
+type Server struct {
+	*httptest.Server
+	ClientID string
+	Key      *rsa.PrivateKey
+	KeyID    string
+	// Discovery, when set, may change the discovery document before it is served
+	Discovery func(document map[string]string)
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// grant is an authorization code waiting to be redeemed
+type grant struct {
+	claims    jwt.MapClaims
+	challenge string
+}
+
+// NewServer starts a provider for clientID, call Close when done
+func NewServer(clientID string) (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	s := &Server{ClientID: clientID, Key: key, KeyID: "test-key", grants: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.serveDiscovery)
+	mux.HandleFunc("/jwks", s.serveKeys)
+	mux.HandleFunc("/token", s.serveToken)
+	s.Server = httptest.NewServer(mux)
+	return s, nil
+}
+
+// Config returns the provider configuration pointing at the server
+func (s *Server) Config(name string) config.OIDCProviderConfig {
+	return config.OIDCProviderConfig{
+		Name:        name,
+		Issuer:      s.URL,
+		ClientID:    s.ClientID,
+		RedirectURL: "http://localhost/callback/" + name,
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+}
+
+// Claims returns valid ID token claims for subject, issued now
+func (s *Server) Claims(subject string, nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   s.URL,
+		"aud":   s.ClientID,
+		"sub":   subject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	}
+}
+
+// Sign signs claims with the server key
+func (s *Server) Sign(claims jwt.MapClaims) (string, error) {
+	return s.SignWith(jwt.SigningMethodRS256, s.KeyID, s.Key, claims)
+}
+
+// SignWith signs claims with any method, key ID and key, to build tokens the
+// provider would not issue
+func (s *Server) SignWith(method jwt.SigningMethod, keyID string, key interface{}, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(method, claims)
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+	return token.SignedString(key)
+}
+
+// Authorize plays the login at the provider for an authorization URL: it
+// returns the code and the state to call back with. The ID token gets
+// claims plus the nonce of the URL, unless claims already holds one.
+func (s *Server) Authorize(authURL string, claims jwt.MapClaims) (string, string, error) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != s.ClientID {
+		return "", "", fmt.Errorf("unexpected client_id %q", query.Get("client_id"))
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		return "", "", fmt.Errorf("unexpected code_challenge_method %q", query.Get("code_challenge_method"))
+	}
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+	return s.IssueCode(claims, query.Get("code_challenge")), query.Get("state"), nil
+}
+
+// IssueCode stores claims under a new authorization code bound to the PKCE challenge
+func (s *Server) IssueCode(claims jwt.MapClaims, challenge string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := fmt.Sprintf("code-%d", len(s.grants)+1)
+	s.grants[code] = grant{claims: claims, challenge: challenge}
+	return code
+}
+
+func (s *Server) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	document := map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	}
+	if s.Discovery != nil {
+		s.Discovery(document)
+	}
+	writeJSON(w, http.StatusOK, document)
+}
+
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request) {
+	public := s.Key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": s.KeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+// serveToken redeems a code once, when client and PKCE verifier match
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("client_id") != s.ClientID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	issued, ok := s.grants[code]
+	delete(s.grants, code)
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != issued.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	idToken, err := s.Sign(issued.claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"token_type": "Bearer", "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
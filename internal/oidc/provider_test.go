@@ -0,0 +1,317 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jinxinyu/go_backend/internal/oidc/oidctest"
+)
+
+func newTestProvider(t *testing.T) (*oidctest.Server, *Provider) {
+	t.Helper()
+	server, err := oidctest.NewServer("test-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server, NewProvider(server.Config("mock"), server.Client())
+}
+
+func TestDiscovery(t *testing.T) {
+	tests := []struct {
+		name      string
+		discovery func(document map[string]string)
+		wantErr   bool
+	}{
+		{name: "valid document"},
+		{
+			name:      "wrong issuer",
+			discovery: func(document map[string]string) { document["issuer"] = "https://evil.example.com" },
+			wantErr:   true,
+		},
+		{
+			name:      "issuer with trailing slash",
+			discovery: func(document map[string]string) { document["issuer"] += "/" },
+		},
+		{
+			name:      "missing token endpoint",
+			discovery: func(document map[string]string) { delete(document, "token_endpoint") },
+			wantErr:   true,
+		},
+		{
+			name:      "missing jwks uri",
+			discovery: func(document map[string]string) { delete(document, "jwks_uri") },
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, provider := newTestProvider(t)
+			server.Discovery = tt.discovery
+
+			authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected discovery to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			parsed, err := url.Parse(authURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := parsed.Query()
+			if !strings.HasPrefix(authURL, server.URL+"/authorize?") {
+				t.Errorf("authorization URL %q does not use the discovered endpoint", authURL)
+			}
+			if query.Get("code_challenge") != CodeChallenge("verifier") || query.Get("code_challenge_method") != "S256" {
+				t.Errorf("authorization URL %q has no S256 challenge of the verifier", authURL)
+			}
+			if query.Get("state") != "state" || query.Get("nonce") != "nonce" || query.Get("client_id") != "test-client" {
+				t.Errorf("authorization URL %q lacks state, nonce or client_id", authURL)
+			}
+		})
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func(server *oidctest.Server) (string, error)
+		nonce string
+		ok    bool
+	}{
+		{
+			name:  "valid",
+			token: func(server *oidctest.Server) (string, error) { return server.Sign(server.Claims("alice", "n-1")) },
+			nonce: "n-1",
+			ok:    true,
+		},
+		{
+			name: "wrong issuer",
+			token: func(server *oidctest.Server) (string, error) {
+				claims := server.Claims("alice", "n-1")
+				claims["iss"] = "https://evil.example.com"
+				return server.Sign(claims)
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "wrong audience",
+			token: func(server *oidctest.Server) (string, error) {
+				claims := server.Claims("alice", "n-1")
+				claims["aud"] = "other-client"
+				return server.Sign(claims)
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "expired",
+			token: func(server *oidctest.Server) (string, error) {
+				claims := server.Claims("alice", "n-1")
+				claims["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+				return server.Sign(claims)
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "expired within leeway",
+			token: func(server *oidctest.Server) (string, error) {
+				claims := server.Claims("alice", "n-1")
+				claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+				return server.Sign(claims)
+			},
+			nonce: "n-1",
+			ok:    true,
+		},
+		{
+			name: "without expiry",
+			token: func(server *oidctest.Server) (string, error) {
+				claims := server.Claims("alice", "n-1")
+				delete(claims, "exp")
+				return server.Sign(claims)
+			},
+			nonce: "n-1",
+		},
+		{
+			name:  "nonce mismatch",
+			token: func(server *oidctest.Server) (string, error) { return server.Sign(server.Claims("alice", "n-1")) },
+			nonce: "n-2",
+		},
+		{
+			name:  "empty nonce",
+			token: func(server *oidctest.Server) (string, error) { return server.Sign(server.Claims("alice", "")) },
+			nonce: "",
+		},
+		{
+			name:  "missing subject",
+			token: func(server *oidctest.Server) (string, error) { return server.Sign(server.Claims("", "n-1")) },
+			nonce: "n-1",
+		},
+		{
+			name: "alg not allowed",
+			token: func(server *oidctest.Server) (string, error) {
+				return server.SignWith(jwt.SigningMethodHS256, server.KeyID, []byte("shared-secret"), server.Claims("alice", "n-1"))
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "alg none",
+			token: func(server *oidctest.Server) (string, error) {
+				return server.SignWith(jwt.SigningMethodNone, server.KeyID, jwt.UnsafeAllowNoneSignatureType, server.Claims("alice", "n-1"))
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "signed by another key",
+			token: func(server *oidctest.Server) (string, error) {
+				return server.SignWith(jwt.SigningMethodRS256, server.KeyID, otherKey, server.Claims("alice", "n-1"))
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "unknown key id",
+			token: func(server *oidctest.Server) (string, error) {
+				return server.SignWith(jwt.SigningMethodRS256, "rotated", server.Key, server.Claims("alice", "n-1"))
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "without key id",
+			token: func(server *oidctest.Server) (string, error) {
+				return server.SignWith(jwt.SigningMethodRS256, "", server.Key, server.Claims("alice", "n-1"))
+			},
+			nonce: "n-1",
+			ok:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, provider := newTestProvider(t)
+			token, err := tt.token(server)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := provider.VerifyIDToken(context.Background(), token, tt.nonce)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if claims.Subject != "alice" {
+				t.Errorf("subject = %q, want alice", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name string
+		// claims returns the ID token claims behind the code
+		claims   func(server *oidctest.Server) jwt.MapClaims
+		code     string
+		verifier string
+		nonce    string
+		wantErr  error
+	}{
+		{
+			name:     "valid",
+			verifier: "verifier",
+			nonce:    "n-1",
+		},
+		{
+			name:     "unknown code",
+			code:     "code-404",
+			verifier: "verifier",
+			nonce:    "n-1",
+			wantErr:  errors.New("invalid_grant"),
+		},
+		{
+			name:     "pkce mismatch",
+			verifier: "another-verifier",
+			nonce:    "n-1",
+			wantErr:  errors.New("invalid_grant"),
+		},
+		{
+			name:     "nonce mismatch",
+			verifier: "verifier",
+			nonce:    "n-2",
+			wantErr:  ErrInvalidIDToken,
+		},
+		{
+			name: "wrong audience",
+			claims: func(server *oidctest.Server) jwt.MapClaims {
+				claims := server.Claims("alice", "n-1")
+				claims["aud"] = "other-client"
+				return claims
+			},
+			verifier: "verifier",
+			nonce:    "n-1",
+			wantErr:  ErrInvalidIDToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, provider := newTestProvider(t)
+			claims := server.Claims("alice", "n-1")
+			if tt.claims != nil {
+				claims = tt.claims(server)
+			}
+			code := server.IssueCode(claims, CodeChallenge("verifier"))
+			if tt.code != "" {
+				code = tt.code
+			}
+
+			got, err := provider.Exchange(context.Background(), code, tt.verifier, tt.nonce)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Exchange: %v", err)
+			case tt.wantErr == nil:
+				if got.Subject != "alice" {
+					t.Errorf("subject = %q, want alice", got.Subject)
+				}
+			case errors.Is(tt.wantErr, ErrInvalidIDToken):
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+				}
+			default:
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExchangeRedeemsCodeOnce(t *testing.T) {
+	server, provider := newTestProvider(t)
+	code := server.IssueCode(server.Claims("alice", "n-1"), CodeChallenge("verifier"))
+
+	if _, err := provider.Exchange(context.Background(), code, "verifier", "n-1"); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, "verifier", "n-1"); err == nil {
+		t.Fatal("expected a redeemed code to be rejected")
+	}
+}
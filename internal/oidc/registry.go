@@ -0,0 +1,41 @@
+package oidc
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/jinxinyu/go_backend/internal/config"
+)
+
+// Registry holds the configured identity providers by name
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry creates a provider client for every configured provider
+func NewRegistry(cfgs []config.OIDCProviderConfig, client *http.Client) *Registry {
+	providers := make(map[string]*Provider, len(cfgs))
+	for _, cfg := range cfgs {
+		providers[cfg.Name] = NewProvider(cfg, client)
+	}
+	return &Registry{providers: providers}
+}
+
+// Get returns the provider called name, or ErrUnknownProvider
+func (r *Registry) Get(name string) (*Provider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Names lists the configured providers in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,82 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// length of the SHA-1 prefix a range lookup is made with, as in the Pwned Passwords API
+const hashPrefixLength = 5
+
+// BreachChecker reports whether a password appeared in a known data breach
+type BreachChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+// BreachList is a local copy of breached password hashes. It is organised like
+// the k-anonymity range API of Pwned Passwords: hashes are grouped by their
+// first five hex characters and a lookup only ever reads one range, so a
+// remote range client can replace it without changing callers.
+type BreachList struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachList reads a file of uppercase or lowercase SHA-1 hex hashes, one
+// per line, optionally followed by ":<count>" as in the Pwned Passwords
+// downloads. Empty lines and lines starting with # are skipped.
+func LoadBreachList(path string) (*BreachList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	list := &BreachList{ranges: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(strings.TrimSpace(hash))
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("breached password list %s line %d: not a SHA-1 hash", path, lineNumber)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("breached password list %s line %d: not a SHA-1 hash", path, lineNumber)
+		}
+		prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+		if list.ranges[prefix] == nil {
+			list.ranges[prefix] = make(map[string]struct{})
+		}
+		list.ranges[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return list, nil
+}
+
+func (l *BreachList) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, found := l.ranges[hash[:hashPrefixLength]][hash[hashPrefixLength:]]
+	return found, nil
+}
+
+// Len returns the number of hashes in the list
+func (l *BreachList) Len() int {
+	count := 0
+	for _, suffixes := range l.ranges {
+		count += len(suffixes)
+	}
+	return count
+}
@@ -0,0 +1,34 @@
+package password
+
+import "strings"
+
+// commonWordRanks ranks frequent passwords and words, most common first. A
+// word found in the password costs its rank in guesses instead of brute force.
+var commonWordRanks = rankWords(`
+	password 123456 12345678 qwerty abc123 monkey letmein dragon 111111 baseball
+	iloveyou trustno1 sunshine master welcome shadow ashley football jesus
+	michael ninja mustang password1 admin login princess starwars solo whatever
+	freedom flower hello charlie donald superman batman qazwsx passw0rd hottie
+	loveme zaq1zaq1 access secret summer winter spring autumn love lovely
+	computer internet google soccer hockey killer pepper jordan jennifer hunter
+	buster thomas tigger robert daniel andrew joshua maggie ginger cheese
+	chocolate cookie banana orange apple purple yellow silver golden diamond
+	angel angels family friends forever happy smile beautiful pokemon naruto
+	matrix hacker coffee money blessed samsung iphone nokia dolphin monster
+	chelsea arsenal liverpool london america canada china japan korea paris
+	berlin test test123 guest user root default changeme temp demo welcome1
+	admin123 letmein1 qwertyuiop asdfghjkl zxcvbnm 1q2w3e4r 1qaz2wsx q1w2e3r4
+	abcdef abcd1234 aaaaaa write writer writing diary journal notebook story
+	novel poetry wang zhang li liu chen yang huang zhao zhou woaini aini
+	wodemima mima
+`)
+
+func rankWords(list string) map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(list) {
+		if _, exists := ranks[word]; !exists {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
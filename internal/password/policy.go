@@ -0,0 +1,166 @@
+// Package password checks new passwords against the configured policy
+package password
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/jinxinyu/go_backend/internal/apperror"
+	"github.com/jinxinyu/go_backend/internal/config"
+)
+
+// Violation codes
+const (
+	CodeTooShort       = "too_short"
+	CodeTooLong        = "too_long"
+	CodeMissingClasses = "missing_character_classes"
+	CodeContainsEmail  = "contains_email"
+	CodeContainsName   = "contains_name"
+	CodeTooWeak        = "too_weak"
+	CodeBreached       = "breached"
+)
+
+// parts of the email or name shorter than this are not looked for in the password
+const minPersonalInfoLength = 3
+
+// ErrPolicyViolation is returned with one field error per broken rule
+var ErrPolicyViolation = apperror.Invalid("password_policy", "password does not meet the policy")
+
+// Policy validates passwords chosen on registration, reset and change
+type Policy struct {
+	minLength  int
+	maxLength  int
+	minClasses int
+	minScore   int
+	breaches   BreachChecker
+}
+
+// NewPolicy creates the policy from the config, loading the breached password
+// list when one is configured
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	if cfg.PasswordMinLength < 1 || cfg.PasswordMaxLength < cfg.PasswordMinLength {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must be positive and not above PASSWORD_MAX_LENGTH")
+	}
+	if cfg.PasswordMinScore < 0 || cfg.PasswordMinScore > MaxScore {
+		return nil, fmt.Errorf("PASSWORD_MIN_SCORE must be between 0 and %d", MaxScore)
+	}
+	policy := &Policy{
+		minLength:  cfg.PasswordMinLength,
+		maxLength:  cfg.PasswordMaxLength,
+		minClasses: cfg.PasswordMinCharacterClasses,
+		minScore:   cfg.PasswordMinScore,
+	}
+	if cfg.PasswordBreachedListFile != "" {
+		breaches, err := LoadBreachList(cfg.PasswordBreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("已加载泄露密码列表: %d 条", breaches.Len())
+		policy.breaches = breaches
+	}
+	return policy, nil
+}
+
+// Validate checks password for the account with the given email and name. It
+// returns ErrPolicyViolation listing the broken rules, or another error when
+// the breach check itself fails.
+func (p *Policy) Validate(ctx context.Context, password string, email string, name string) error {
+	var violations []apperror.FieldError
+	add := func(code string, format string, args ...interface{}) {
+		violations = append(violations, apperror.FieldError{Field: "password", Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := len([]rune(password))
+	if length < p.minLength {
+		add(CodeTooShort, "must be at least %d characters long", p.minLength)
+	}
+	if length > p.maxLength {
+		add(CodeTooLong, "must be at most %d characters long", p.maxLength)
+	}
+	if classes := characterClasses(password); classes < p.minClasses {
+		add(CodeMissingClasses, "must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.minClasses)
+	}
+
+	lower := strings.ToLower(password)
+	// the domain is shared with many people, only the local part is personal
+	localPart, _, _ := strings.Cut(email, "@")
+	emailParts := personalInfo(localPart, func(r rune) bool { return r == '.' || r == '+' || r == '-' || r == '_' })
+	nameParts := personalInfo(name, func(r rune) bool { return unicode.IsSpace(r) || r == '-' || r == '.' })
+	if containsAny(lower, emailParts) {
+		add(CodeContainsEmail, "must not contain your email address")
+	}
+	if containsAny(lower, nameParts) {
+		add(CodeContainsName, "must not contain your name")
+	}
+
+	// a password too long to be accepted is not worth scoring
+	if length <= p.maxLength && Score(password, append(emailParts, nameParts...)...) < p.minScore {
+		add(CodeTooWeak, "is too easy to guess, avoid common words, names, dates and keyboard patterns")
+	}
+
+	if p.breaches != nil {
+		breached, err := p.breaches.IsBreached(ctx, password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			add(CodeBreached, "has appeared in a data breach, please choose another one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return ErrPolicyViolation.WithFields(violations)
+	}
+	return nil
+}
+
+// characterClasses counts the classes among lowercase, uppercase, digits and symbols used in s
+func characterClasses(s string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	count := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// personalInfo returns the lowercased value and its parts long enough to matter
+func personalInfo(value string, separator func(rune) bool) []string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len([]rune(value)) < minPersonalInfoLength {
+		return nil
+	}
+	parts := []string{value}
+	for _, part := range strings.FieldsFunc(value, separator) {
+		if len([]rune(part)) >= minPersonalInfoLength && part != value {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func containsAny(s string, parts []string) bool {
+	for _, part := range parts {
+		if strings.Contains(s, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// MaxScore is the score of a strong password
+const MaxScore = 4
+
+// log10 of the guesses separating the scores, as in zxcvbn
+var scoreThresholds = [MaxScore]float64{3, 6, 8, 10}
+
+// keyboard rows walked by common passwords
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// Score rates a password from 0 (trivial) to MaxScore (strong) in the spirit
+// of zxcvbn: the password is split into the cheapest sequence of patterns an
+// attacker would try (common words, the user's own data, repeats, sequences,
+// keyboard runs, and brute force for the rest) and the score follows from the
+// estimated number of guesses.
+func Score(password string, userInputs ...string) int {
+	guesses := estimateGuessesLog10(password, userInputs)
+	score := 0
+	for _, threshold := range scoreThresholds {
+		if guesses >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+// estimateGuessesLog10 returns log10 of the guesses needed for password
+func estimateGuessesLog10(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	if len(lower) != len(runes) {
+		// a few runes change length when lowercased, compare as is then
+		lower = runes
+	}
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	inputs := make(map[string]int, len(userInputs))
+	for i, input := range userInputs {
+		if input != "" {
+			inputs[strings.ToLower(input)] = i + 1
+		}
+	}
+
+	// best[j] is the cheapest cost (log10 guesses) to cover the first j runes
+	best := make([]float64, n+1)
+	for j := 1; j <= n; j++ {
+		best[j] = best[j-1] + math.Log10(bruteforceCardinality(runes[j-1]))
+		for i := 0; i <= j-3; i++ {
+			if cost, ok := patternCost(runes[i:j], lower[i:j], inputs); ok && best[i]+cost < best[j] {
+				best[j] = best[i] + cost
+			}
+		}
+	}
+	return best[n]
+}
+
+// patternCost returns log10 of the guesses for a segment matching a known
+// pattern, ok is false when the segment is none of them
+func patternCost(original []rune, lower []rune, inputs map[string]int) (float64, bool) {
+	word := string(lower)
+	length := float64(len(lower))
+	cost := math.Inf(1)
+
+	if rank, ok := inputs[word]; ok {
+		cost = math.Min(cost, math.Log10(float64(rank)*casingVariations(original)))
+	}
+	if rank, ok := commonWordRanks[word]; ok {
+		cost = math.Min(cost, math.Log10(float64(rank)*casingVariations(original)))
+	}
+	if isRepeat(lower) {
+		cost = math.Min(cost, math.Log10(bruteforceCardinality(lower[0])*length))
+	}
+	if step, ok := sequenceStep(lower); ok {
+		guesses := 26.0
+		if unicode.IsDigit(lower[0]) || lower[0] == 'a' || lower[0] == 'z' {
+			guesses = 4
+		}
+		if step < 0 {
+			guesses *= 2
+		}
+		cost = math.Min(cost, math.Log10(guesses*length))
+	}
+	if onKeyboardRow(word) {
+		cost = math.Min(cost, math.Log10(float64(len(keyboardRows))*20*length))
+	}
+	return cost, !math.IsInf(cost, 1)
+}
+
+// bruteforceCardinality is the size of the alphabet r is guessed from
+func bruteforceCardinality(r rune) float64 {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r < unicode.MaxASCII:
+		return 33
+	default:
+		return 100
+	}
+}
+
+// casingVariations is the guess multiplier of a dictionary word written with uppercase letters
+func casingVariations(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case upper == len(word), upper == 1 && unicode.IsUpper(word[0]):
+		// all caps or capitalized, tried first
+		return 2
+	default:
+		return math.Pow(2, float64(upper))
+	}
+}
+
+func isRepeat(word []rune) bool {
+	for _, r := range word[1:] {
+		if r != word[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// sequenceStep reports whether word is a run like "abcd" or "9876"
+func sequenceStep(word []rune) (int, bool) {
+	step := int(word[1]) - int(word[0])
+	if step != 1 && step != -1 {
+		return 0, false
+	}
+	for i := 2; i < len(word); i++ {
+		if int(word[i])-int(word[i-1]) != step {
+			return 0, false
+		}
+	}
+	return step, true
+}
+
+func onKeyboardRow(word string) bool {
+	reversed := []rune(word)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	for _, row := range keyboardRows {
+		if strings.Contains(row, word) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,17 +2,26 @@
 package router
 
 import (
+	"expvar"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jinxinyu/go_backend/internal/audit"
 	"github.com/jinxinyu/go_backend/internal/auth"
 	"github.com/jinxinyu/go_backend/internal/middleware"
+	"github.com/jinxinyu/go_backend/internal/utils"
+	"github.com/jinxinyu/go_backend/internal/writing"
 )
 
 // SetupRouter configures the HTTP router for the application
-func SetupRouter(authService *auth.Service) *gin.Engine {
+func SetupRouter(authService *auth.Service, writingService *writing.Service, auditRecorder *audit.Recorder, tokenmaker utils.ToKenGenerator) *gin.Engine {
 	r := gin.Default()
+	// must come first so it also sees errors of the middleware below
+	r.Use(middleware.ErrorHandler())
+	// client address and user agent for the audit log
+	r.Use(middleware.ClientContext())
 	config := &middleware.CorsOptions{
 		AllowAllOrigins:  []string{"http://localhost:3000"},
-		AllowAllMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowAllMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowAllHeaders:  []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin", "Cache-Control", "X-Requested-With"},
 		AllowCredentials: true,
 	}
@@ -24,10 +33,31 @@ func SetupRouter(authService *auth.Service) *gin.Engine {
 			"status": "ok",
 		})
 	})
+	// public keys for services verifying our access tokens
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, tokenmaker.PublicKeys())
+	})
 
-	// Register auth routes
 	apiv1 := r.Group("/api/v1")
-	auth.RegisterUserRoutes(apiv1, authService)
+
+	// Protected routes: handlers registered on this group can read the caller
+	// through middleware.GetClaims / middleware.GetUserID
+	authorized := apiv1.Group("")
+	authorized.Use(middleware.AuthMiddleware(authService))
+
+	// Scoped routes: like authorized, but personal access tokens are accepted
+	// for the scope each route declares
+	scoped := apiv1.Group("")
+	scoped.Use(middleware.ScopedAuthMiddleware(authService))
+
+	// Register auth routes
+	auth.RegisterUserRoutes(apiv1, authorized, authService)
+	auth.RegisterAdminRoutes(authorized, authService)
+	// runtime metrics (expvar), e.g. the password hashing pool
+	authorized.GET("/admin/metrics", middleware.RequirePermission(utils.PermissionMetricsRead), gin.WrapH(expvar.Handler()))
+	authorized.GET("/admin/audit", middleware.RequirePermission(utils.PermissionAuditRead), audit.NewHandler(auditRecorder).ListEvents)
+	writing.RegisterWritingRoutes(scoped, writingService)
 	// Add more routes here...
 
 	return r
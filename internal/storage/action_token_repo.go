@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActionTokenRepository defines the interface for single use token operations
+type ActionTokenRepository interface {
+	Create(ctx context.Context, token *models.ActionToken) error
+	// Consume atomically marks an unused, unexpired token as used and returns it.
+	// ErrRecordNotFound is returned when no such token exists.
+	Consume(ctx context.Context, purpose string, tokenHash string, now time.Time) (*models.ActionToken, error)
+	// InvalidateForUser marks every outstanding token of the user for purpose as used
+	InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string, now time.Time) error
+	CountCreatedSince(ctx context.Context, userID uuid.UUID, purpose string, since time.Time) (int64, error)
+}
+
+type actionTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewActionTokenRepository(db *gorm.DB) ActionTokenRepository {
+	return &actionTokenRepository{db: db}
+}
+
+func (r *actionTokenRepository) Create(ctx context.Context, token *models.ActionToken) error {
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	result := r.db.WithContext(ctx).Create(token)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create action token: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *actionTokenRepository) Consume(ctx context.Context, purpose string, tokenHash string, now time.Time) (*models.ActionToken, error) {
+	var tokens []models.ActionToken
+	result := r.db.WithContext(ctx).Model(&tokens).
+		Clauses(clause.Returning{}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume action token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || len(tokens) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &tokens[0], nil
+}
+
+func (r *actionTokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID, purpose string, now time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to invalidate action tokens: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *actionTokenRepository) CountCreatedSince(ctx context.Context, userID uuid.UUID, purpose string, since time.Time) (int64, error) {
+	var count int64
+	result := r.db.WithContext(ctx).Model(&models.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, since).
+		Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to count action tokens: %w", result.Error)
+	}
+	return count, nil
+}
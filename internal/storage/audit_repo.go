@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// auditChainLockKey serializes appends, each row must see the hash of the one before
+const auditChainLockKey = 0x617564697463 // "auditc"
+
+// AuditFilter narrows down audit events, zero fields match everything
+type AuditFilter struct {
+	EventType string
+	ActorID   *uuid.UUID
+	SubjectID *uuid.UUID
+	From      *time.Time
+	To        *time.Time
+}
+
+// AuditRepository defines the interface for the audit log. There is no update
+// or delete, rows are only ever appended.
+type AuditRepository interface {
+	// Append links event to the last row of the chain and stores it
+	Append(ctx context.Context, event *models.AuditEvent) error
+	// List returns a page of matching events, newest first, and the number of matches
+	List(ctx context.Context, filter AuditFilter, offset int, limit int) ([]models.AuditEvent, int64, error)
+	// ListAfter returns up to limit events with an ID above afterID, in chain order
+	ListAfter(ctx context.Context, afterID uint64, limit int) ([]models.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockKey).Error; err != nil {
+			return fmt.Errorf("failed to lock audit chain: %w", err)
+		}
+		var last models.AuditEvent
+		err := tx.Select("hash").Order("id DESC").Take(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get last audit event: %w", err)
+		}
+
+		// postgres keeps microseconds, the hash must match what is read back
+		event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Microsecond)
+		event.PrevHash = last.Hash
+		event.Hash = event.ComputeHash()
+		if err := tx.Create(event).Error; err != nil {
+			return fmt.Errorf("failed to create audit event: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *auditRepository) List(ctx context.Context, filter AuditFilter, offset int, limit int) ([]models.AuditEvent, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.AuditEvent{})
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.SubjectID != nil {
+		query = query.Where("subject_id = ?", *filter.SubjectID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+	var events []models.AuditEvent
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, total, nil
+}
+
+func (r *auditRepository) ListAfter(ctx context.Context, afterID uint64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	result := r.db.WithContext(ctx).Where("id > ?", afterID).Order("id").Limit(limit).Find(&events)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", result.Error)
+	}
+	return events, nil
+}
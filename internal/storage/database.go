@@ -7,6 +7,7 @@ import (
 
 	"github.com/jinxinyu/go_backend/internal/config"
 	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/textstats"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -82,9 +83,27 @@ func ConnectDatabase(cfg *config.Config) (*gorm.DB, error) {
 	log.Printf("开始自动迁移表结构")
 	migrateStart := time.Now()
 
+	if err := migrateWriteLogStats(db, textstats.NewCounter(cfg)); err != nil {
+		log.Printf("写作日志统计迁移失败: %v", err)
+		return nil, err
+	}
+
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.WriteLog{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.UserTokenRevocation{},
+		&models.ActionToken{},
+		&models.RecoveryCode{},
+		&models.LoginAttempt{},
+		&models.UserIdentity{},
+		&models.PersonalAccessToken{},
+		&models.Session{},
+		&models.Role{},
+		&models.UserRole{},
+		&models.AuditEvent{},
+		&models.Invitation{},
 	); err != nil {
 		log.Printf("自动迁移失败: %v", err)
 		return nil, fmt.Errorf("failed to auto migrate: %v", err)
@@ -92,5 +111,58 @@ func ConnectDatabase(cfg *config.Config) (*gorm.DB, error) {
 		log.Printf("自动迁移成功，耗时: %v", time.Since(migrateStart))
 	}
 
+	if err := normalizeUserEmails(db); err != nil {
+		log.Printf("邮箱地址规范化失败: %v", err)
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// migrateWriteLogStats adds the kana and Hangul column to an existing
+// write_logs table and recounts every log: their words_count was sent by the
+// client or counted without kana and Hangul, and older rows have no counts at
+// all. DDL is transactional in postgres, so the column only appears together
+// with the recounted values.
+func migrateWriteLogStats(db *gorm.DB, counter *textstats.Counter) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.WriteLog{}) || migrator.HasColumn(&models.WriteLog{}, "KanaHangulCount") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.WriteLog{}); err != nil {
+			return fmt.Errorf("failed to migrate write logs: %w", err)
+		}
+		var logs []models.WriteLog
+		result := tx.Select("id", "content").FindInBatches(&logs, 500, func(_ *gorm.DB, _ int) error {
+			for i := range logs {
+				logs[i].SetStats(counter.Count(logs[i].Content))
+				if err := tx.Model(&logs[i]).
+					Select("words_count", "han_count", "kana_hangul_count", "latin_word_count", "number_count", "punctuation_count", "paragraph_count", "character_count").
+					UpdateColumns(&logs[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to recount write logs: %w", result.Error)
+		}
+		log.Printf("已重新统计 %d 条写作日志", result.RowsAffected)
+		return nil
+	})
+}
+
+// normalizeUserEmails rewrites addresses stored before every write normalized
+// them, so lookups can use the unique email index with a plain comparison.
+// Accounts whose addresses only differ in case make it fail and must be merged by hand.
+func normalizeUserEmails(db *gorm.DB) error {
+	result := db.Exec("UPDATE users SET email = lower(btrim(email)) WHERE email <> lower(btrim(email))")
+	if result.Error != nil {
+		return fmt.Errorf("failed to normalize user emails: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("已规范化 %d 个邮箱地址", result.RowsAffected)
+	}
+	return nil
+}
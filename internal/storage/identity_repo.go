@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// IdentityRepository defines the interface for linked external identity operations
+type IdentityRepository interface {
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	GetByProviderSubject(ctx context.Context, provider string, subject string) (*models.UserIdentity, error)
+	TouchLogin(ctx context.Context, id uuid.UUID, at time.Time) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error)
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	if identity.ID == uuid.Nil {
+		identity.ID = uuid.New()
+	}
+	result := r.db.WithContext(ctx).Create(identity)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create identity: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *identityRepository) GetByProviderSubject(ctx context.Context, provider string, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	result := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", result.Error)
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) TouchLogin(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.UserIdentity{}).Where("id = ?", id).Update("last_login_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update identity: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *identityRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", result.Error)
+	}
+	return identities, nil
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInviteQuotaExceeded is returned by CreateWithinQuota when the inviter has no seats left
+var ErrInviteQuotaExceeded = errors.New("invite quota exceeded")
+
+// InvitationRepository defines the interface for invitation operations
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *models.Invitation) error
+	// CreateWithinQuota creates invitation when its seats and the ones its
+	// inviter reserved fit in their invite quota, ErrInviteQuotaExceeded
+	// otherwise. The inviter row is locked meanwhile, so concurrent requests
+	// cannot both take the last seats. It returns the quota and the seats
+	// reserved before the invitation.
+	CreateWithinQuota(ctx context.Context, invitation *models.Invitation, now time.Time) (int, int64, error)
+	GetByCode(ctx context.Context, code string) (*models.Invitation, error)
+	// List returns a page of all invitations, newest first
+	List(ctx context.Context, offset int, limit int) ([]models.Invitation, int64, error)
+	ListByInviter(ctx context.Context, inviterID uuid.UUID) ([]models.Invitation, error)
+	// ReservedSeats counts the registrations the invitations of the inviter
+	// still allow plus the ones they were used for, admin issued ones aside
+	ReservedSeats(ctx context.Context, inviterID uuid.UUID, now time.Time) (int64, error)
+	// Redeem takes one use of a usable invitation, ErrRecordNotFound if it is
+	// used up, expired or revoked. Release gives the use back.
+	Redeem(ctx context.Context, id uuid.UUID, now time.Time) (*models.Invitation, error)
+	Release(ctx context.Context, id uuid.UUID) error
+	// Revoke revokes an invitation, of the inviter only when inviterID is not
+	// nil. ErrRecordNotFound if there is no such active invitation.
+	Revoke(ctx context.Context, id uuid.UUID, inviterID *uuid.UUID, revokedAt time.Time) error
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	if invitation.ID == uuid.Nil {
+		invitation.ID = uuid.New()
+	}
+	result := r.db.WithContext(ctx).Create(invitation)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create invitation: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *invitationRepository) CreateWithinQuota(ctx context.Context, invitation *models.Invitation, now time.Time) (int, int64, error) {
+	if invitation.ID == uuid.Nil {
+		invitation.ID = uuid.New()
+	}
+	var quota int
+	var reserved int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var inviter models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "invite_quota").
+			Where("id = ?", *invitation.InviterID).
+			Take(&inviter).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecordNotFound
+			}
+			return fmt.Errorf("failed to lock inviter: %w", err)
+		}
+		quota = inviter.InviteQuota
+
+		if reserved, err = reservedSeats(tx, inviter.ID, now); err != nil {
+			return err
+		}
+		if reserved+int64(invitation.MaxUses) > int64(quota) {
+			return ErrInviteQuotaExceeded
+		}
+		if err := tx.Create(invitation).Error; err != nil {
+			return fmt.Errorf("failed to create invitation: %w", err)
+		}
+		return nil
+	})
+	return quota, reserved, err
+}
+
+func (r *invitationRepository) GetByCode(ctx context.Context, code string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	result := r.db.WithContext(ctx).Where("code = ?", code).First(&invitation)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", result.Error)
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) List(ctx context.Context, offset int, limit int) ([]models.Invitation, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Invitation{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count invitations: %w", err)
+	}
+	var invitations []models.Invitation
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&invitations).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invitations, total, nil
+}
+
+func (r *invitationRepository) ListByInviter(ctx context.Context, inviterID uuid.UUID) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	result := r.db.WithContext(ctx).
+		Where("inviter_id = ?", inviterID).
+		Order("created_at DESC").
+		Find(&invitations)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", result.Error)
+	}
+	return invitations, nil
+}
+
+func (r *invitationRepository) ReservedSeats(ctx context.Context, inviterID uuid.UUID, now time.Time) (int64, error) {
+	return reservedSeats(r.db.WithContext(ctx), inviterID, now)
+}
+
+func reservedSeats(db *gorm.DB, inviterID uuid.UUID, now time.Time) (int64, error) {
+	var seats int64
+	// an invitation that can no longer be redeemed only holds the seats it used
+	result := db.Model(&models.Invitation{}).
+		Select("COALESCE(SUM(CASE WHEN revoked_at IS NULL AND expires_at > ? THEN max_uses ELSE uses END), 0)", now).
+		Where("inviter_id = ? AND NOT admin_issued", inviterID).
+		Scan(&seats)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to count invitation seats: %w", result.Error)
+	}
+	return seats, nil
+}
+
+func (r *invitationRepository) Redeem(ctx context.Context, id uuid.UUID, now time.Time) (*models.Invitation, error) {
+	var invitations []models.Invitation
+	result := r.db.WithContext(ctx).Model(&invitations).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND revoked_at IS NULL AND uses < max_uses AND expires_at > ?", id, now).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to redeem invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || len(invitations) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &invitations[0], nil
+}
+
+func (r *invitationRepository) Release(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&models.Invitation{}).
+		Where("id = ? AND uses > 0", id).
+		UpdateColumn("uses", gorm.Expr("uses - 1"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to release invitation: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *invitationRepository) Revoke(ctx context.Context, id uuid.UUID, inviterID *uuid.UUID, revokedAt time.Time) error {
+	query := r.db.WithContext(ctx).Model(&models.Invitation{}).
+		Where("id = ? AND revoked_at IS NULL", id)
+	if inviterID != nil {
+		query = query.Where("inviter_id = ?", *inviterID)
+	}
+	result := query.Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
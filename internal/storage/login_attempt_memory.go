@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/models"
+)
+
+// sweep the map every so many writes so keys of one-off IPs do not pile up
+const memoryAttemptSweepEvery = 1024
+
+// memoryLoginAttemptStore is an in-process LoginAttemptStore. Counters are
+// per instance and lost on restart.
+type memoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+	writes   int
+}
+
+// NewMemoryLoginAttemptStore returns an in-memory LoginAttemptStore
+func NewMemoryLoginAttemptStore() LoginAttemptStore {
+	return &memoryLoginAttemptStore{attempts: make(map[string]models.LoginAttempt)}
+}
+
+func (m *memoryLoginAttemptStore) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &attempt, nil
+}
+
+func (m *memoryLoginAttemptStore) RegisterFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.writes++
+	if m.writes%memoryAttemptSweepEvery == 0 {
+		m.sweep(now, window)
+	}
+
+	attempt, ok := m.attempts[key]
+	if !ok || attempt.LastFailureAt.Before(now.Add(-window)) {
+		attempt.Key = key
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	m.attempts[key] = attempt
+	return &attempt, nil
+}
+
+func (m *memoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return nil
+	}
+	attempt.Failures = 0
+	attempt.LockedUntil = &until
+	m.attempts[key] = attempt
+	return nil
+}
+
+func (m *memoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
+
+// sweep drops keys that neither count recent failures nor are locked, m.mu must be held
+func (m *memoryLoginAttemptStore) sweep(now time.Time, window time.Duration) {
+	for key, attempt := range m.attempts {
+		locked := attempt.LockedUntil != nil && attempt.LockedUntil.After(now)
+		if !locked && attempt.LastFailureAt.Before(now.Add(-window)) {
+			delete(m.attempts, key)
+		}
+	}
+}
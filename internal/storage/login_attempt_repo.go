@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// LoginAttemptStore keeps the failed login counters used for brute-force protection
+type LoginAttemptStore interface {
+	Get(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RegisterFailure increments the counter of key. Failures older than window
+	// are forgotten, so the count restarts at 1.
+	RegisterFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+	// Lock blocks key until the given time and clears its failure counter
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginAttemptRepository returns a Postgres backed LoginAttemptStore
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptStore {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	result := r.db.WithContext(ctx).Where("attempt_key = ?", key).First(&attempt)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to get login attempt: %w", result.Error)
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) RegisterFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	// a single upsert keeps concurrent failures from losing increments
+	var attempt models.LoginAttempt
+	result := r.db.WithContext(ctx).Raw(`
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING attempt_key, failures, last_failure_at, locked_until`,
+		key, now, now.Add(-window)).Scan(&attempt)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to register login failure: %w", result.Error)
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.LoginAttempt{}).Where("attempt_key = ?", key).Updates(map[string]interface{}{
+		"failures":     0,
+		"locked_until": until,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to lock login key: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) Reset(ctx context.Context, key string) error {
+	result := r.db.WithContext(ctx).Where("attempt_key = ?", key).Delete(&models.LoginAttempt{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", result.Error)
+	}
+	return nil
+}
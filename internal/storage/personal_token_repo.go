@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// PersonalAccessTokenRepository defines the interface for personal access token operations
+type PersonalAccessTokenRepository interface {
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.PersonalAccessToken, error)
+	// Revoke revokes a token of the user, ErrRecordNotFound if the user has no such active token
+	Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID, revokedAt time.Time) error
+	// TouchLastUsed records a use, writes older than the given cutoff only to keep
+	// busy tokens from updating the row on every request
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time, olderThan time.Time) error
+}
+
+type personalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{db: db}
+}
+
+func (r *personalAccessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	result := r.db.WithContext(ctx).Create(token)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create personal access token: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	result := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to get personal access token: %w", result.Error)
+	}
+	return &token, nil
+}
+
+func (r *personalAccessTokenRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&tokens)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list personal access tokens: %w", result.Error)
+	}
+	return tokens, nil
+}
+
+func (r *personalAccessTokenRepository) Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID, revokedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke personal access token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *personalAccessTokenRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time, olderThan time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, olderThan).
+		Update("last_used_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update personal access token: %w", result.Error)
+	}
+	return nil
+}
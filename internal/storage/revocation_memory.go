@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type userRevocation struct {
+	revokedBefore time.Time
+	expiresAt     time.Time
+}
+
+// memoryRevocationStore is an in-process RevocationStore, suitable for a single
+// instance deployment or development. Entries are lost on restart.
+type memoryRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[uuid.UUID]userRevocation
+}
+
+// NewMemoryRevocationStore returns an in-memory RevocationStore
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[uuid.UUID]userRevocation),
+	}
+}
+
+func (m *memoryRevocationStore) Revoke(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+func (m *memoryRevocationStore) RevokeAllForUser(ctx context.Context, userID uuid.UUID, revokedBefore time.Time, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[userID] = userRevocation{revokedBefore: revokedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *memoryRevocationStore) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.tokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+	if entry, ok := m.users[userID]; ok && !entry.revokedBefore.Before(issuedAt) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *memoryRevocationStore) PruneExpired(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pruned int64
+	for jti, expiresAt := range m.tokens {
+		if expiresAt.Before(now) {
+			delete(m.tokens, jti)
+			pruned++
+		}
+	}
+	for userID, entry := range m.users {
+		if entry.expiresAt.Before(now) {
+			delete(m.users, userID)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationStore keeps track of access tokens that were revoked before their expiry
+type RevocationStore interface {
+	// Revoke blocks a single token until expiresAt
+	Revoke(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error
+	// RevokeAllForUser blocks every token of the user issued up to revokedBefore.
+	// expiresAt must be at least revokedBefore plus the access token lifetime.
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID, revokedBefore time.Time, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+	// PruneExpired removes entries that no longer block any valid token
+	PruneExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+// NewRevocationRepository returns a Postgres backed RevocationStore
+func NewRevocationRepository(db *gorm.DB) RevocationStore {
+	return &revocationRepository{db: db}
+}
+
+func (r *revocationRepository) Revoke(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	entry := &models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke token: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *revocationRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, revokedBefore time.Time, expiresAt time.Time) error {
+	entry := &models.UserTokenRevocation{UserID: userID, RevokedBefore: revokedBefore, ExpiresAt: expiresAt}
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "expires_at"}),
+	}).Create(entry)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *revocationRepository) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	var count int64
+	if jti != "" {
+		result := r.db.WithContext(ctx).Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count)
+		if result.Error != nil {
+			return false, fmt.Errorf("failed to check revoked token: %w", result.Error)
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	result := r.db.WithContext(ctx).Model(&models.UserTokenRevocation{}).
+		Where("user_id = ? AND revoked_before >= ?", userID, issuedAt).Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to check user token revocation: %w", result.Error)
+	}
+	return count > 0, nil
+}
+
+func (r *revocationRepository) PruneExpired(ctx context.Context, now time.Time) (int64, error) {
+	tokens := r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	if tokens.Error != nil {
+		return 0, fmt.Errorf("failed to prune revoked tokens: %w", tokens.Error)
+	}
+	users := r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.UserTokenRevocation{})
+	if users.Error != nil {
+		return tokens.RowsAffected, fmt.Errorf("failed to prune user token revocations: %w", users.Error)
+	}
+	return tokens.RowsAffected + users.RowsAffected, nil
+}
+
+// RunRevocationPruner periodically removes expired entries from the store until ctx is done
+func RunRevocationPruner(ctx context.Context, store RevocationStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pruned, err := store.PruneExpired(ctx, now)
+			if err != nil {
+				log.Printf("清理过期的吊销记录失败: %v", err)
+				continue
+			}
+			if pruned > 0 {
+				log.Printf("已清理 %d 条过期的吊销记录", pruned)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoleRepository defines the interface for role and role assignment operations
+type RoleRepository interface {
+	// Upsert creates the role or updates the description and permissions of the role with the same name
+	Upsert(ctx context.Context, role *models.Role) error
+	List(ctx context.Context) ([]models.Role, error)
+	GetByNames(ctx context.Context, names []string) ([]models.Role, error)
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]models.Role, error)
+	// Grant gives the user a role, granting a role the user already has is a no-op
+	Grant(ctx context.Context, userID uuid.UUID, roleID uuid.UUID, grantedBy *uuid.UUID) error
+	// SetForUser replaces the roles of the user
+	SetForUser(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID, grantedBy *uuid.UUID) error
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) Upsert(ctx context.Context, role *models.Role) error {
+	if role.ID == uuid.Nil {
+		role.ID = uuid.New()
+	}
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "permissions"}),
+	}).Create(role)
+	if result.Error != nil {
+		return fmt.Errorf("failed to upsert role: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *roleRepository) List(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	result := r.db.WithContext(ctx).Order("name").Find(&roles)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", result.Error)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetByNames(ctx context.Context, names []string) ([]models.Role, error) {
+	var roles []models.Role
+	if len(names) == 0 {
+		return roles, nil
+	}
+	result := r.db.WithContext(ctx).Where("name IN ?", names).Order("name").Find(&roles)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", result.Error)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]models.Role, error) {
+	var roles []models.Role
+	result := r.db.WithContext(ctx).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list roles of user: %w", result.Error)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) Grant(ctx context.Context, userID uuid.UUID, roleID uuid.UUID, grantedBy *uuid.UUID) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserRole{
+		UserID:    userID,
+		RoleID:    roleID,
+		GrantedBy: grantedBy,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to grant role: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *roleRepository) SetForUser(ctx context.Context, userID uuid.UUID, roleIDs []uuid.UUID, grantedBy *uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// keep existing grants so their history is not lost
+		remove := tx.Where("user_id = ?", userID)
+		if len(roleIDs) > 0 {
+			remove = remove.Where("role_id NOT IN ?", roleIDs)
+		}
+		if err := remove.Delete(&models.UserRole{}).Error; err != nil {
+			return fmt.Errorf("failed to remove roles: %w", err)
+		}
+		for _, roleID := range roleIDs {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserRole{
+				UserID:    userID,
+				RoleID:    roleID,
+				GrantedBy: grantedBy,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to grant role: %w", err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionRepository defines the interface for login session operations
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	// ListActiveByUser returns the sessions that are neither revoked nor expired, most recently seen first
+	ListActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error)
+	TouchLastSeen(ctx context.Context, id uuid.UUID, at time.Time) error
+	// Extend records a token refresh: the client may have moved, and the session lives on
+	Extend(ctx context.Context, id uuid.UUID, ip string, userAgent string, at time.Time, expiresAt time.Time) error
+	// Revoke revokes a session of the user, ErrRecordNotFound if the user has no such active session
+	Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID, revokedAt time.Time) error
+	// RevokeAllForUser revokes every active session of the user but except and returns their IDs
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID, except uuid.UUID, revokedAt time.Time) ([]uuid.UUID, error)
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *models.Session) error {
+	if session.ID == uuid.Nil {
+		session.ID = uuid.New()
+	}
+	result := r.db.WithContext(ctx).Create(session)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create session: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *sessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	result := r.db.WithContext(ctx).Where("id = ?", id).First(&session)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", result.Error)
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) ListActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", result.Error)
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) TouchLastSeen(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Update("last_seen_at", at)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update session: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *sessionRepository) Extend(ctx context.Context, id uuid.UUID, ip string, userAgent string, at time.Time, expiresAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"ip":           ip,
+		"user_agent":   userAgent,
+		"last_seen_at": at,
+		"expires_at":   expiresAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to extend session: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID, revokedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, except uuid.UUID, revokedAt time.Time) ([]uuid.UUID, error) {
+	var revoked []models.Session
+	result := r.db.WithContext(ctx).Model(&revoked).
+		Clauses(clause.Returning{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, except).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to revoke sessions of user: %w", result.Error)
+	}
+	ids := make([]uuid.UUID, 0, len(revoked))
+	for _, session := range revoked {
+		ids = append(ids, session.ID)
+	}
+	return ids, nil
+}
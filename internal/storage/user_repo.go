@@ -5,22 +5,55 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jinxinyu/go_backend/internal/apperror"
 	"github.com/jinxinyu/go_backend/internal/models"
 	"gorm.io/gorm"
 )
 
-// ErrRecordNotFound is an error that is returned when a record is not found
-var ErrRecordNotFound = errors.New("record not found")
+// ErrRecordNotFound is an error that is returned when a record is not found,
+// it answers 404 when it reaches a client unhandled
+var ErrRecordNotFound = apperror.NotFound("not_found", "record not found")
+
+// ErrEmailTaken is returned by Create when another account already uses the email
+var ErrEmailTaken = errors.New("email already registered")
+
+// pgUniqueViolation is the SQLSTATE of a unique constraint violation
+const pgUniqueViolation = "23505"
 
 // UserRepository defines the interface for user operations
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
-	//update
-	//delete
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, verifiedAt time.Time) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword string) error
+	UpdateProfile(ctx context.Context, id uuid.UUID, name string, timeZone string) error
+	// SetPendingEmail remembers the address an email change waits to confirm
+	SetPendingEmail(ctx context.Context, id uuid.UUID, email string) error
+	// UpdateEmail switches to a confirmed address, which counts as verified
+	UpdateEmail(ctx context.Context, id uuid.UUID, email string, verifiedAt time.Time) error
+	// UpdateTwoFactor stores the secret and switch. Turning 2FA on keeps the last
+	// used TOTP step, so the code that confirmed the secret cannot be used again.
+	UpdateTwoFactor(ctx context.Context, id uuid.UUID, secret string, enabled bool) error
+	SetInviteQuota(ctx context.Context, id uuid.UUID, quota int) error
+	// AdvanceTwoFactorStep records step as the last used TOTP step, it reports
+	// false when a code of this or a later step was already accepted
+	AdvanceTwoFactorStep(ctx context.Context, id uuid.UUID, step int64) (bool, error)
+	// List returns a page of users whose name or email contains search, newest
+	// first, together with the number of matching users
+	List(ctx context.Context, search string, offset int, limit int) ([]models.User, int64, error)
+	// SoftDelete marks the user as deleted, Restore undoes it
+	SoftDelete(ctx context.Context, id uuid.UUID, deletedAt time.Time) error
+	Restore(ctx context.Context, id uuid.UUID) error
+	// ListDeletedBefore returns up to limit users deleted before the given time
+	ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]models.User, error)
+	// Purge removes the user and every row belonging to them, in one transaction
+	Purge(ctx context.Context, id uuid.UUID) error
 }
 
 type userRepository struct {
@@ -35,8 +68,14 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	if user.ID == uuid.Nil {
 		user.ID = uuid.New()
 	}
+	user.Email = models.NormalizeEmail(user.Email)
 	result := r.db.WithContext(ctx).Create(user)
 	if result.Error != nil {
+		// the email is the only unique column next to the random id
+		var pgErr *pgconn.PgError
+		if errors.As(result.Error, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return ErrEmailTaken
+		}
 		return fmt.Errorf("failed to create user: %w", result.Error)
 	}
 	return nil
@@ -56,7 +95,7 @@ func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	result := r.db.WithContext(ctx).Where("email = ?", email).First(&user)
+	result := r.db.WithContext(ctx).Where("email = ?", models.NormalizeEmail(email)).First(&user)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrRecordNotFound
@@ -65,3 +104,206 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 	}
 	return &user, nil
 }
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, verifiedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": verifiedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark email as verified: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("password", hashedPassword)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update password: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateProfile(ctx context.Context, id uuid.UUID, name string, timeZone string) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"name":      name,
+		"time_zone": timeZone,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update profile: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) SetPendingEmail(ctx context.Context, id uuid.UUID, email string) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("pending_email", models.NormalizeEmail(email))
+	if result.Error != nil {
+		return fmt.Errorf("failed to set pending email: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string, verifiedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"email":             models.NormalizeEmail(email),
+		"pending_email":     "",
+		"email_verified":    true,
+		"email_verified_at": verifiedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update email: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) SetInviteQuota(ctx context.Context, id uuid.UUID, quota int) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("invite_quota", quota)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update invite quota: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateTwoFactor(ctx context.Context, id uuid.UUID, secret string, enabled bool) error {
+	updates := map[string]interface{}{
+		"two_factor_secret":  secret,
+		"two_factor_enabled": enabled,
+	}
+	if !enabled {
+		// a new or removed secret starts without used steps
+		updates["two_factor_last_step"] = 0
+	}
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update two-factor settings: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) AdvanceTwoFactorStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", id, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update two-factor step: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *userRepository) List(ctx context.Context, search string, offset int, limit int) ([]models.User, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.User{})
+	if search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	var users []models.User
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) SoftDelete(ctx context.Context, id uuid.UUID, deletedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ? AND deleted_at IS NULL", id).Update("deleted_at", deletedAt)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to restore user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	result := r.db.WithContext(ctx).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Order("deleted_at").Limit(limit).Find(&users)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list deleted users: %w", result.Error)
+	}
+	return users, nil
+}
+
+// userOwnedModels are the tables holding rows of a user in a user_id column
+var userOwnedModels = []interface{}{
+	&models.WriteLog{},
+	&models.RefreshToken{},
+	&models.RevokedToken{},
+	&models.UserTokenRevocation{},
+	&models.ActionToken{},
+	&models.RecoveryCode{},
+	&models.UserIdentity{},
+	&models.PersonalAccessToken{},
+	&models.Session{},
+	&models.UserRole{},
+}
+
+func (r *userRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range userOwnedModels {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to purge %T of user: %w", model, err)
+			}
+		}
+		// roles the user granted to others stay, without the granter
+		if err := tx.Model(&models.UserRole{}).Where("granted_by = ?", id).Update("granted_by", nil).Error; err != nil {
+			return fmt.Errorf("failed to clear granted roles of user: %w", err)
+		}
+		// so do the invitations of the user and the accounts they brought in
+		if err := tx.Model(&models.Invitation{}).Where("inviter_id = ?", id).Update("inviter_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to clear invitations of user: %w", err)
+		}
+		if err := tx.Model(&models.User{}).Where("invited_by_id = ?", id).Update("invited_by_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to clear invitees of user: %w", err)
+		}
+		result := tx.Where("id = ?", id).Delete(&models.User{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge user: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// escapeLike escapes the LIKE wildcards in user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -16,11 +16,43 @@ import (
 type WriteLogRepository interface {
 	CreateLog(ctx context.Context, log *models.WriteLog) error
 	UpdateLog(ctx context.Context, log *models.WriteLog) error
-	GetLogByID(ctx context.Context, id uuid.UUID) (*models.WriteLog, error)
+	// GetLogByID returns a log of the user, ErrRecordNotFound if the user has no such log
+	GetLogByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*models.WriteLog, error)
 	GetLogByUserID(ctx context.Context, userID uuid.UUID) ([]*models.WriteLog, error)
 	GetLogByDate(ctx context.Context, userID uuid.UUID, date time.Time) ([]*models.WriteLog, error)
 	GetLogByDateRange(ctx context.Context, userID uuid.UUID, startDate time.Time, endDate time.Time) ([]*models.WriteLog, error)
-	DeleteLog(ctx context.Context, id uuid.UUID) error
+	// DeleteLog deletes a log of the user, ErrRecordNotFound if the user has no such log
+	DeleteLog(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+	// Aggregate sums the logs of the user dated from to to, both included, per
+	// day, ISO week or month. Every bucket of the range is returned, empty
+	// ones with zero counts.
+	Aggregate(ctx context.Context, userID uuid.UUID, period string, from time.Time, to time.Time) ([]WriteLogBucket, error)
+}
+
+// Aggregation periods
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// WriteLogBucket holds the totals of the logs of one period, Start is its
+// first day (the Monday of an ISO week)
+type WriteLogBucket struct {
+	Start         time.Time `gorm:"column:bucket"`
+	Logs          int64     `gorm:"column:logs"`
+	Words         int64     `gorm:"column:words"`
+	HanCharacters int64     `gorm:"column:han_characters"`
+	KanaHangul    int64     `gorm:"column:kana_hangul"`
+	LatinWords    int64     `gorm:"column:latin_words"`
+	ActiveDays    int64     `gorm:"column:active_days"` //days with at least one log
+}
+
+// periodIntervals maps a period to the step between its buckets
+var periodIntervals = map[string]string{
+	PeriodDay:   "1 day",
+	PeriodWeek:  "1 week",
+	PeriodMonth: "1 month",
 }
 
 type writeLogRepository struct {
@@ -41,23 +73,34 @@ func (r *writeLogRepository) CreateLog(ctx context.Context, log *models.WriteLog
 
 func (r *writeLogRepository) UpdateLog(ctx context.Context, log *models.WriteLog) error {
 	result := r.db.WithContext(ctx).Model(&models.WriteLog{}).Where("id = ? AND user_id = ?", log.ID, log.UserID).Updates(map[string]interface{}{
-		"word_count": log.WordsCount,
-		"content":    log.Content,
+		"date":              log.Date,
+		"words_count":       log.WordsCount,
+		"content":           log.Content,
+		"han_count":         log.HanCount,
+		"kana_hangul_count": log.KanaHangulCount,
+		"latin_word_count":  log.LatinWordCount,
+		"number_count":      log.NumberCount,
+		"punctuation_count": log.PunctuationCount,
+		"paragraph_count":   log.ParagraphCount,
+		"character_count":   log.CharacterCount,
 		//gorm will automatically update the updated_at field because of the autoUpdateTime
 	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update log: %w", result.Error)
+	}
 	//check if the log was updated
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("log not found(ID: %s, UserID: %s)", log.ID, log.UserID)
+		return ErrRecordNotFound
 	}
 	return nil
 }
 
-func (r *writeLogRepository) GetLogByID(ctx context.Context, id uuid.UUID) (*models.WriteLog, error) {
+func (r *writeLogRepository) GetLogByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*models.WriteLog, error) {
 	var log models.WriteLog
-	result := r.db.WithContext(ctx).Where("id = ?", id).First(&log)
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&log)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("log not found with id %s", id)
+			return nil, ErrRecordNotFound
 		}
 		return nil, fmt.Errorf("failed to get log: %w", result.Error)
 	}
@@ -75,9 +118,9 @@ func (r *writeLogRepository) GetLogByUserID(ctx context.Context, userID uuid.UUI
 
 func (r *writeLogRepository) GetLogByDate(ctx context.Context, userID uuid.UUID, date time.Time) ([]*models.WriteLog, error) {
 	var logs []*models.WriteLog
-	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
-	result := r.db.WithContext(ctx).Where("user_id = ? AND date BETWEEN ? AND ?", userID, startOfDay, endOfDay).Order("created_at asc").Find(&logs)
+	//date is a date column, BETWEEN the next midnight would also match the next day
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	result := r.db.WithContext(ctx).Where("user_id = ? AND date = ?", userID, day).Order("created_at asc").Find(&logs)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get logs: %v", result.Error)
 	}
@@ -86,17 +129,64 @@ func (r *writeLogRepository) GetLogByDate(ctx context.Context, userID uuid.UUID,
 
 func (r *writeLogRepository) GetLogByDateRange(ctx context.Context, userID uuid.UUID, startDate time.Time, endDate time.Time) ([]*models.WriteLog, error) {
 	var logs []*models.WriteLog
-	result := r.db.WithContext(ctx).Where("user_id = ? AND date BETWEEN ? AND ?", userID, startDate, endDate).Order("created_at asc").Find(&logs)
+	result := r.db.WithContext(ctx).Where("user_id = ? AND date BETWEEN ? AND ?", userID, startDate, endDate).Order("date asc, created_at asc").Find(&logs)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get logs: %v", result.Error)
 	}
 	return logs, nil
 }
 
-func (r *writeLogRepository) DeleteLog(ctx context.Context, id uuid.UUID) error {
-	result := r.db.WithContext(ctx).Delete(&models.WriteLog{}, id)
+func (r *writeLogRepository) DeleteLog(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.WriteLog{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete log: %v", result.Error)
 	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
 	return nil
 }
+
+func (r *writeLogRepository) Aggregate(ctx context.Context, userID uuid.UUID, period string, from time.Time, to time.Time) ([]WriteLogBucket, error) {
+	interval, ok := periodIntervals[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown aggregation period %q", period)
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	//the date column already holds the day of the user, it is truncated as a
+	//timestamp without time zone so the session time zone plays no part.
+	//generate_series yields every bucket and the left join keeps the empty ones.
+	var buckets []WriteLogBucket
+	result := r.db.WithContext(ctx).Raw(`
+		SELECT b.bucket,
+			COUNT(l.id) AS logs,
+			COALESCE(SUM(l.words_count), 0) AS words,
+			COALESCE(SUM(l.han_count), 0) AS han_characters,
+			COALESCE(SUM(l.kana_hangul_count), 0) AS kana_hangul,
+			COALESCE(SUM(l.latin_word_count), 0) AS latin_words,
+			COUNT(DISTINCT l.date) AS active_days
+		FROM generate_series(
+			date_trunc(@period, CAST(@from AS date)::timestamp),
+			date_trunc(@period, CAST(@to AS date)::timestamp),
+			CAST(@interval AS interval)
+		) AS b(bucket)
+		LEFT JOIN write_logs l
+			ON l.user_id = @user
+			AND l.date BETWEEN CAST(@from AS date) AND CAST(@to AS date)
+			AND date_trunc(@period, l.date::timestamp) = b.bucket
+		GROUP BY b.bucket
+		ORDER BY b.bucket`,
+		map[string]interface{}{
+			"period":   period,
+			"interval": interval,
+			"from":     from.Format("2006-01-02"),
+			"to":       to.Format("2006-01-02"),
+			"user":     userID,
+		}).Scan(&buckets)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to aggregate logs: %w", result.Error)
+	}
+	return buckets, nil
+}
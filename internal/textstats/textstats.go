@@ -0,0 +1,150 @@
+// Package textstats counts the words and characters of mixed Chinese and
+// Latin text
+package textstats
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/jinxinyu/go_backend/internal/config"
+)
+
+// Stats are the counts of one text. Han characters, kana and Hangul are
+// counted one by one, scripts written with spaces (Latin, Cyrillic...) by words.
+type Stats struct {
+	HanCharacters int
+	KanaHangul    int // Japanese kana and Korean Hangul characters
+	LatinWords    int
+	Numbers       int // runs of digits such as 2024 or 3.14
+	Punctuation   int
+	Paragraphs    int // blocks of text separated by blank lines
+	Characters    int // every character but white space
+	// Words is the total word count according to the rules of the Counter
+	Words int
+}
+
+// Rules decide how mixed-script text adds up to Words
+type Rules struct {
+	// CountPunctuation adds punctuation to Words, like the Chinese word count
+	// of most word processors
+	CountPunctuation bool
+	// CountNumbers adds numbers to Words
+	CountNumbers bool
+	// JoinHyphenated counts "well-known" as one word instead of two
+	JoinHyphenated bool
+}
+
+// Counter computes Stats with fixed rules
+type Counter struct {
+	rules Rules
+}
+
+// NewCounter creates a counter with the rules of the config
+func NewCounter(cfg *config.Config) *Counter {
+	return NewCounterWithRules(Rules{
+		CountPunctuation: cfg.TextStatsCountPunctuation,
+		CountNumbers:     cfg.TextStatsCountNumbers,
+		JoinHyphenated:   cfg.TextStatsJoinHyphenated,
+	})
+}
+
+func NewCounterWithRules(rules Rules) *Counter {
+	return &Counter{rules: rules}
+}
+
+// Count returns the statistics of text
+func (c *Counter) Count(text string) Stats {
+	var stats Stats
+	inParagraph := false
+	for _, line := range strings.Split(text, "\n") {
+		blank := strings.TrimSpace(line) == ""
+		if !blank && !inParagraph {
+			stats.Paragraphs++
+		}
+		inParagraph = !blank
+	}
+
+	runes := []rune(text)
+	// a token is a word when it has a letter, a number otherwise
+	inToken, tokenHasLetter := false, false
+	endToken := func() {
+		if !inToken {
+			return
+		}
+		if tokenHasLetter {
+			stats.LatinWords++
+		} else {
+			stats.Numbers++
+		}
+		inToken, tokenHasLetter = false, false
+	}
+
+	for i, r := range runes {
+		if !unicode.IsSpace(r) {
+			stats.Characters++
+		}
+		switch {
+		case unicode.Is(unicode.Han, r):
+			endToken()
+			stats.HanCharacters++
+		case isKanaHangul(r):
+			endToken()
+			stats.KanaHangul++
+		case isWordRune(r):
+			inToken = true
+			if !unicode.IsDigit(r) {
+				tokenHasLetter = true
+			}
+		case inToken && c.joins(runes, i, tokenHasLetter):
+			// part of the word, e.g. don't, well-known, 3.14 or 1,000
+		default:
+			endToken()
+			if unicode.IsPunct(r) {
+				stats.Punctuation++
+			}
+		}
+	}
+	endToken()
+
+	stats.Words = stats.HanCharacters + stats.KanaHangul + stats.LatinWords
+	if c.rules.CountNumbers {
+		stats.Words += stats.Numbers
+	}
+	if c.rules.CountPunctuation {
+		stats.Words += stats.Punctuation
+	}
+	return stats
+}
+
+// joins reports whether the connector at runes[i] continues the current word.
+// It must sit between two word characters: apostrophes and hyphens inside
+// words, decimal points and thousand separators inside numbers.
+func (c *Counter) joins(runes []rune, i int, inWord bool) bool {
+	if i+1 >= len(runes) || !isWordRune(runes[i+1]) {
+		return false
+	}
+	switch runes[i] {
+	case '\'', '’':
+		return inWord
+	case '-':
+		return inWord && c.rules.JoinHyphenated
+	case '.', ',':
+		return !inWord && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1])
+	}
+	return false
+}
+
+// isWordRune reports whether r belongs to a word of a script written with
+// spaces. Marks are included so combining accents do not split words.
+func isWordRune(r rune) bool {
+	if unicode.Is(unicode.Han, r) || isKanaHangul(r) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// isKanaHangul reports whether r is a kana or Hangul character. The prolonged
+// sound mark (ー) belongs to no script but is written inside katakana words.
+func isKanaHangul(r rune) bool {
+	return unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || r == 'ー' || r == 'ｰ'
+}
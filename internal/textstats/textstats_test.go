@@ -0,0 +1,94 @@
+package textstats
+
+import "testing"
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		text  string
+		want  Stats
+	}{
+		{
+			name: "mixed Han and Latin",
+			text: "我爱Go语言和open source",
+			want: Stats{HanCharacters: 5, LatinWords: 3, Paragraphs: 1, Characters: 17, Words: 8},
+		},
+		{
+			name: "apostrophe inside a word",
+			text: "don't stop",
+			want: Stats{LatinWords: 2, Paragraphs: 1, Characters: 9, Words: 2},
+		},
+		{
+			name: "curly apostrophe inside a word",
+			text: "it’s",
+			want: Stats{LatinWords: 1, Paragraphs: 1, Characters: 4, Words: 1},
+		},
+		{
+			name:  "hyphenated word joined",
+			rules: Rules{JoinHyphenated: true},
+			text:  "a well-known fact",
+			want:  Stats{LatinWords: 3, Paragraphs: 1, Characters: 15, Words: 3},
+		},
+		{
+			name: "hyphenated word split",
+			text: "a well-known fact",
+			want: Stats{LatinWords: 4, Punctuation: 1, Paragraphs: 1, Characters: 15, Words: 4},
+		},
+		{
+			name: "decimal number",
+			text: "pi is 3.14",
+			want: Stats{LatinWords: 2, Numbers: 1, Paragraphs: 1, Characters: 8, Words: 2},
+		},
+		{
+			name:  "thousands separator counted as number",
+			rules: Rules{CountNumbers: true},
+			text:  "1,000 words",
+			want:  Stats{LatinWords: 1, Numbers: 1, Paragraphs: 1, Characters: 10, Words: 2},
+		},
+		{
+			name: "comma after a number ends it",
+			text: "1, 2",
+			want: Stats{Numbers: 2, Punctuation: 1, Paragraphs: 1, Characters: 3, Words: 0},
+		},
+		{
+			name:  "full-width punctuation",
+			rules: Rules{CountPunctuation: true},
+			text:  "你好，世界！「好」。",
+			want:  Stats{HanCharacters: 5, Punctuation: 5, Paragraphs: 1, Characters: 10, Words: 10},
+		},
+		{
+			name: "full-width punctuation not counted",
+			text: "你好，世界！",
+			want: Stats{HanCharacters: 4, Punctuation: 2, Paragraphs: 1, Characters: 6, Words: 4},
+		},
+		{
+			name: "kana and Hangul counted per character",
+			text: "コーヒーをください 안녕하세요",
+			want: Stats{KanaHangul: 14, Paragraphs: 1, Characters: 14, Words: 14},
+		},
+		{
+			name: "kana next to Latin",
+			text: "Goのテスト",
+			want: Stats{KanaHangul: 4, LatinWords: 1, Paragraphs: 1, Characters: 6, Words: 5},
+		},
+		{
+			name: "paragraphs are separated by blank lines",
+			text: "first line\nsame paragraph\n\n  \nsecond\r\n\r\nthird\n",
+			want: Stats{LatinWords: 6, Paragraphs: 3, Characters: 33, Words: 6},
+		},
+		{
+			name: "empty",
+			text: " \n\n ",
+			want: Stats{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewCounterWithRules(tt.rules).Count(tt.text)
+			if got != tt.want {
+				t.Errorf("Count(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,18 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/alexedwards/argon2id"
+	"github.com/jinxinyu/go_backend/internal/config"
 )
 
+// upper bound for the startup benchmark, it only ever raises the iterations
+const maxTunedArgon2Iterations = 16
+
 // sturct to store the hash and salt
 type argon2idHash struct {
 	params *argon2id.Params
@@ -13,8 +20,11 @@ type argon2idHash struct {
 
 // define an interface for the hashed password
 type HashedPassword interface {
-	Compare(password string, storedhash string) (bool, error)
-	Hash(password string) (string, error)
+	Compare(ctx context.Context, password string, storedhash string) (bool, error)
+	Hash(ctx context.Context, password string) (string, error)
+	// NeedsRehash reports whether storedhash was made with weaker parameters
+	// than the current ones and should be replaced on the next login
+	NeedsRehash(storedhash string) bool
 }
 
 // instance of the hashed password
@@ -25,7 +35,46 @@ func NewHashedPassword(params *argon2id.Params) HashedPassword {
 	return &argon2idHash{params: params}
 }
 
-func (h *argon2idHash) Hash(password string) (string, error) {
+// NewArgon2Params builds the argon2id parameters from the config and, when a
+// target latency is set, tunes the iterations to it on this machine
+func NewArgon2Params(cfg *config.Config) (*argon2id.Params, error) {
+	params := &argon2id.Params{
+		Memory:      cfg.Argon2MemoryKiB,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+		SaltLength:  cfg.Argon2SaltLength,
+		KeyLength:   cfg.Argon2KeyLength,
+	}
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, errors.New("ARGON2_MEMORY_KIB, ARGON2_ITERATIONS and ARGON2_PARALLELISM must be positive, with at least 8 KiB of memory per lane")
+	}
+	if params.SaltLength < 16 || params.KeyLength < 16 {
+		return nil, errors.New("ARGON2_SALT_LENGTH and ARGON2_KEY_LENGTH must be at least 16")
+	}
+	if cfg.Argon2TuneTargetMS > 0 {
+		return TuneArgon2Params(params, time.Duration(cfg.Argon2TuneTargetMS)*time.Millisecond)
+	}
+	return params, nil
+}
+
+// TuneArgon2Params raises the iterations of base until hashing one password
+// takes at least target. Memory and parallelism are kept, and the result is
+// never cheaper than base.
+func TuneArgon2Params(base *argon2id.Params, target time.Duration) (*argon2id.Params, error) {
+	tuned := *base
+	for {
+		start := time.Now()
+		if _, err := argon2id.CreateHash("benchmark-password", &tuned); err != nil {
+			return nil, fmt.Errorf("failed to benchmark argon2id: %w", err)
+		}
+		if time.Since(start) >= target || tuned.Iterations >= maxTunedArgon2Iterations {
+			return &tuned, nil
+		}
+		tuned.Iterations++
+	}
+}
+
+func (h *argon2idHash) Hash(ctx context.Context, password string) (string, error) {
 	hash, err := argon2id.CreateHash(password, h.params)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
@@ -33,7 +82,7 @@ func (h *argon2idHash) Hash(password string) (string, error) {
 	return hash, nil
 }
 
-func (h *argon2idHash) Compare(password string, storedhash string) (bool, error) {
+func (h *argon2idHash) Compare(ctx context.Context, password string, storedhash string) (bool, error) {
 	match, err := argon2id.ComparePasswordAndHash(password, storedhash)
 	if err != nil {
 		return false, fmt.Errorf("invalid password: %w", err)
@@ -41,3 +90,17 @@ func (h *argon2idHash) Compare(password string, storedhash string) (bool, error)
 	return match, nil
 
 }
+
+func (h *argon2idHash) NeedsRehash(storedhash string) bool {
+	params, salt, key, err := argon2id.DecodeHash(storedhash)
+	if err != nil {
+		return true
+	}
+	// only weaker hashes are replaced, so servers tuned to slightly different
+	// iterations do not keep rewriting each other's hashes. Parallelism does
+	// not change the strength and is left alone.
+	return params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		uint32(len(salt)) < h.params.SaltLength ||
+		uint32(len(key)) < h.params.KeyLength
+}
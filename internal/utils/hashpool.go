@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/jinxinyu/go_backend/internal/apperror"
+)
+
+// ErrHashPoolSaturated is returned when no hashing worker frees up within the queue timeout
+var ErrHashPoolSaturated = apperror.Unavailable("server_busy", "服务器繁忙，请稍后再试").WithRetryAfter(time.Second)
+
+// hashPoolMetrics is served as "password_hash_pool" by the expvar handler on /admin/metrics
+var hashPoolMetrics = expvar.NewMap("password_hash_pool")
+
+// boundedHasher runs the hashes of the wrapped HashedPassword on a fixed number
+// of workers. Every argon2id call allocates the configured memory, so the
+// number of workers caps what hashing can take under a burst of logins.
+type boundedHasher struct {
+	next         HashedPassword
+	workers      chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewBoundedHashedPassword limits next to workers concurrent hashes. Callers
+// wait for a free worker at most queueTimeout, or until their context ends.
+func NewBoundedHashedPassword(next HashedPassword, workers int, queueTimeout time.Duration) HashedPassword {
+	if workers < 1 {
+		workers = 1
+	}
+	hashPoolMetrics.Set("workers", expvarInt(int64(workers)))
+	return &boundedHasher{
+		next:         next,
+		workers:      make(chan struct{}, workers),
+		queueTimeout: queueTimeout,
+	}
+}
+
+func (b *boundedHasher) Hash(ctx context.Context, password string) (string, error) {
+	if err := b.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer b.release()
+	return b.next.Hash(ctx, password)
+}
+
+func (b *boundedHasher) Compare(ctx context.Context, password string, storedhash string) (bool, error) {
+	if err := b.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer b.release()
+	return b.next.Compare(ctx, password, storedhash)
+}
+
+func (b *boundedHasher) NeedsRehash(storedhash string) bool {
+	return b.next.NeedsRehash(storedhash)
+}
+
+// acquire waits for a free worker
+func (b *boundedHasher) acquire(ctx context.Context) error {
+	// fast path, no queueing
+	select {
+	case b.workers <- struct{}{}:
+		hashPoolMetrics.Add("in_flight", 1)
+		return nil
+	default:
+	}
+
+	hashPoolMetrics.Add("queued", 1)
+	defer hashPoolMetrics.Add("queued", -1)
+	start := time.Now()
+	timer := time.NewTimer(b.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case b.workers <- struct{}{}:
+		hashPoolMetrics.Add("in_flight", 1)
+		hashPoolMetrics.Add("wait_ms_total", time.Since(start).Milliseconds())
+		return nil
+	case <-timer.C:
+		hashPoolMetrics.Add("rejected_total", 1)
+		return ErrHashPoolSaturated
+	case <-ctx.Done():
+		hashPoolMetrics.Add("canceled_total", 1)
+		return ctx.Err()
+	}
+}
+
+func (b *boundedHasher) release() {
+	<-b.workers
+	hashPoolMetrics.Add("in_flight", -1)
+	hashPoolMetrics.Add("completed_total", 1)
+}
+
+func expvarInt(value int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(value)
+	return v
+}
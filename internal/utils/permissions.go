@@ -0,0 +1,36 @@
+package utils
+
+import "slices"
+
+// Permissions gate administrative endpoints, users get them through roles
+const (
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write"
+	PermissionMetricsRead      = "metrics:read"
+	PermissionAuditRead        = "audit:read"
+	PermissionInvitationsRead  = "invitations:read"
+	PermissionInvitationsWrite = "invitations:write"
+)
+
+// RoleAdmin is the built-in role holding every permission
+const RoleAdmin = "admin"
+
+// AllPermissions lists every permission in display order
+var AllPermissions = []string{PermissionUsersRead, PermissionUsersWrite, PermissionMetricsRead, PermissionAuditRead, PermissionInvitationsRead, PermissionInvitationsWrite}
+
+// BuiltinRoles are created at startup with these permissions, other roles
+// can be added to the roles table
+var BuiltinRoles = map[string][]string{
+	RoleAdmin: AllPermissions,
+}
+
+// IsValidPermission reports whether permission is a known permission
+func IsValidPermission(permission string) bool {
+	return slices.Contains(AllPermissions, permission)
+}
+
+// HasPermission reports whether the caller was granted permission by one of
+// their roles. Personal access tokens never carry permissions.
+func (c *Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "slices"
+
+// Scopes a personal access token can be granted
+const (
+	ScopeLogsRead  = "logs:read"
+	ScopeLogsWrite = "logs:write"
+	ScopeStatsRead = "stats:read"
+)
+
+// PersonalTokenPrefix starts every personal access token, it tells them apart
+// from JWTs and makes leaked tokens easy to spot
+const PersonalTokenPrefix = "wpat_"
+
+// AllScopes lists every scope in display order
+var AllScopes = []string{ScopeLogsRead, ScopeLogsWrite, ScopeStatsRead}
+
+// IsValidScope reports whether scope is a known scope
+func IsValidScope(scope string) bool {
+	return slices.Contains(AllScopes, scope)
+}
+
+// HasScope reports whether the caller may use scope. Session tokens are not
+// restricted, personal access tokens only get the scopes they were created with.
+func (c *Claims) HasScope(scope string) bool {
+	if !c.PersonalToken {
+		return true
+	}
+	return slices.Contains(c.Scopes, scope)
+}
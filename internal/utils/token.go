@@ -1,12 +1,16 @@
 package utils
 
 import (
+	"context"
+	"crypto"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/apperror"
 	"github.com/jinxinyu/go_backend/internal/config"
 )
 
@@ -14,74 +18,232 @@ import (
 type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
+	// login session the token belongs to, uuid.Nil for tokens issued before
+	// sessions were tracked
+	SessionID uuid.UUID `json:"sid"`
+	// roles of the user and the permissions they grant, as of token issue
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// set when the caller authenticated with a personal access token instead
+	// of a session JWT, Scopes then lists what the token may do
+	PersonalToken bool     `json:"-"`
+	Scopes        []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// TokenSubject describes the user and session an access token is issued for
+type TokenSubject struct {
+	UserID      uuid.UUID
+	Email       string
+	SessionID   uuid.UUID
+	Roles       []string
+	Permissions []string
+}
+
+var (
+	// ErrInvalidToken is returned by ValidateToken for tokens that fail to parse or verify
+	ErrInvalidToken = apperror.Unauthorized("invalid_token", "invalid token")
+	// ErrTokenExpired is returned by ValidateToken for expired or not yet valid tokens
+	ErrTokenExpired = apperror.Unauthorized("token_expired", "token is expired or not valid yet")
+	// ErrTokenRevoked is returned by ValidateToken for tokens revoked server-side
+	ErrTokenRevoked = apperror.Unauthorized("token_revoked", "token has been revoked")
+)
+
 // define a struct to represent the token
 type ToKenGenerator interface {
-	GenerateToken(userID uuid.UUID, email string) (string, error)
-	ValidateToken(token string) (*Claims, error)
+	GenerateToken(subject TokenSubject) (string, error)
+	ValidateToken(ctx context.Context, token string) (*Claims, error)
+	// PublicKeys returns the keys other services use to verify our tokens,
+	// it is empty for HS256 where the secret cannot be published
+	PublicKeys() JWKSet
+}
+
+// RevocationChecker reports whether a token was revoked before its expiry
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
 }
 
 type jwtTokenGenerator struct {
 	SecretKey     []byte
 	TokenDuration time.Duration
+	revocations   RevocationChecker
+
+	// asymmetric signing, unused with HS256
+	method     jwt.SigningMethod
+	signingKey crypto.Signer
+	keyID      string
+	// verification keys by kid, the signing key is always part of it
+	verifyKeys map[string]verificationKey
 }
 
-func NewTokenGenerator(cfg *config.Config) (ToKenGenerator, error) {
-	if cfg.JWTSecret == "" {
-		return nil, errors.New("JWT_SECRET is not set")
-	}
+// NewTokenGenerator creates the JWT generator. revocations may be nil, in which
+// case tokens are valid until they expire.
+func NewTokenGenerator(cfg *config.Config, revocations RevocationChecker) (ToKenGenerator, error) {
 	if cfg.JWTExpirationMinutes <= 0 {
 		return nil, errors.New("JWT_EXPIRATION_MINUTES must bigger than 0")
 	}
-	return &jwtTokenGenerator{
-		SecretKey:     []byte(cfg.JWTSecret),
+	generator := &jwtTokenGenerator{
 		TokenDuration: time.Duration(cfg.JWTExpirationMinutes) * time.Minute,
-	}, nil
+		revocations:   revocations,
+	}
+
+	switch cfg.JWTAlgorithm {
+	case "", AlgorithmHS256:
+		if cfg.JWTSecret == "" {
+			return nil, errors.New("JWT_SECRET is not set")
+		}
+		generator.method = jwt.SigningMethodHS256
+		generator.SecretKey = []byte(cfg.JWTSecret)
+		generator.keyID = cfg.JWTKeyID
+		return generator, nil
+	case AlgorithmRS256, AlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", cfg.JWTAlgorithm)
+	}
+
+	if cfg.JWTPrivateKeyFile == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", cfg.JWTAlgorithm)
+	}
+	signingKey, err := loadPrivateKey(cfg.JWTPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	method, err := signingMethodFor(signingKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if method.Alg() != cfg.JWTAlgorithm {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE holds a %s key but JWT_ALGORITHM is %s", method.Alg(), cfg.JWTAlgorithm)
+	}
+	keyID := cfg.JWTKeyID
+	if keyID == "" {
+		if keyID, err = keyThumbprint(signingKey.Public()); err != nil {
+			return nil, err
+		}
+	}
+
+	// previous keys stay valid for verification while their tokens expire
+	previous, err := parseVerificationKeys(cfg.JWTVerificationKeys)
+	if err != nil {
+		return nil, err
+	}
+	generator.method = method
+	generator.signingKey = signingKey
+	generator.keyID = keyID
+	generator.verifyKeys = map[string]verificationKey{
+		keyID: {kid: keyID, method: method, key: signingKey.Public()},
+	}
+	for _, key := range previous {
+		if _, exists := generator.verifyKeys[key.kid]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id %q", key.kid)
+		}
+		generator.verifyKeys[key.kid] = key
+	}
+	return generator, nil
 }
 
-func (t *jwtTokenGenerator) GenerateToken(userID uuid.UUID, email string) (string, error) {
+func (t *jwtTokenGenerator) PublicKeys() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range t.verifyKeys {
+		jwk, err := toJWK(key)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	// stable order, the current signing key first
+	sort.Slice(set.Keys, func(i, j int) bool {
+		if (set.Keys[i].Kid == t.keyID) != (set.Keys[j].Kid == t.keyID) {
+			return set.Keys[i].Kid == t.keyID
+		}
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+func (t *jwtTokenGenerator) GenerateToken(subject TokenSubject) (string, error) {
 	expirationTime := time.Now().Add(t.TokenDuration)
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:      subject.UserID,
+		Email:       subject.Email,
+		SessionID:   subject.SessionID,
+		Roles:       subject.Roles,
+		Permissions: subject.Permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "go_backend",
-			Subject:   userID.String(),
+			Subject:   subject.UserID.String(),
+			ID:        uuid.New().String(),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(t.SecretKey)
+	token := jwt.NewWithClaims(t.method, claims)
+	if t.keyID != "" {
+		token.Header["kid"] = t.keyID
+	}
+	var key interface{} = t.SecretKey
+	if t.signingKey != nil {
+		key = t.signingKey
+	}
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate token: %w", err)
 	}
 	return tokenString, nil
 }
 
-func (t *jwtTokenGenerator) ValidateToken(tokenString string) (*Claims, error) {
+func (t *jwtTokenGenerator) ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return t.SecretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, t.verificationKey)
 	//check some errors like invalid token, expired token, etc.
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenNotValidYet) {
-			return nil, fmt.Errorf("token is expired or not valid yet")
+			return nil, ErrTokenExpired
 		}
 		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, fmt.Errorf("invalid token")
+			return nil, ErrInvalidToken
 		}
-		return nil, fmt.Errorf("invalid token: %w", err)
+		return nil, ErrInvalidToken.WithDetail("%v", err)
 	}
 	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+		return nil, ErrInvalidToken
+	}
+
+	if t.revocations != nil {
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		revoked, err := t.revocations.IsRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
 	}
 	return claims, nil
 }
+
+// verificationKey picks the key for a token: the secret with HS256, otherwise
+// the public key named by the kid header
+func (t *jwtTokenGenerator) verificationKey(token *jwt.Token) (interface{}, error) {
+	if t.signingKey == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return t.SecretKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := t.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	// never let the token choose a different algorithm than the key's
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.key, nil
+}
@@ -0,0 +1,10 @@
+package writing
+
+import "github.com/jinxinyu/go_backend/internal/apperror"
+
+var (
+	// ErrLogNotFound is returned for a log that does not exist or belongs to another user
+	ErrLogNotFound = apperror.NotFound("write_log_not_found", "writing log not found")
+	// ErrInvalidDateRange is returned when a range ends before it starts or spans too many days
+	ErrInvalidDateRange = apperror.Invalid("invalid_date_range", "invalid date range")
+)
@@ -0,0 +1,172 @@
+package writing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/apperror"
+	"github.com/jinxinyu/go_backend/internal/middleware"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateLog(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var req api.CreateWriteLogRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	writeLog, err := h.service.CreateLog(ctx, userID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"log": writeLog})
+}
+
+func (h *Handler) ListLogs(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var query api.ListWriteLogsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.ListLogs(ctx, userID, &query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetLog(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	logID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	writeLog, err := h.service.GetLog(ctx, userID, logID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"log": writeLog})
+}
+
+func (h *Handler) UpdateLog(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	logID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req api.UpdateWriteLogRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	writeLog, err := h.service.UpdateLog(ctx, userID, logID, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"log": writeLog})
+}
+
+func (h *Handler) DeleteLog(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	logID, ok := middleware.ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteLog(ctx, userID, logID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetStats(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		_ = c.Error(apperror.ErrUnauthorized)
+		return
+	}
+
+	var query api.StatsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	resp, err := h.service.Stats(ctx, userID, &query)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
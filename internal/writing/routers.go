@@ -0,0 +1,25 @@
+package writing
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinxinyu/go_backend/internal/middleware"
+	"github.com/jinxinyu/go_backend/internal/utils"
+)
+
+// RegisterWritingRoutes registers the writing log and statistics routes on
+// scoped, a group behind middleware.ScopedAuthMiddleware. Personal access
+// tokens need the logs:read, logs:write or stats:read scope.
+func RegisterWritingRoutes(scoped *gin.RouterGroup, service *Service) {
+	handler := NewHandler(service)
+
+	logRoutes := scoped.Group("/logs")
+	{
+		logRoutes.GET("", middleware.RequireScope(utils.ScopeLogsRead), handler.ListLogs)
+		logRoutes.GET("/:id", middleware.RequireScope(utils.ScopeLogsRead), handler.GetLog)
+		logRoutes.POST("", middleware.RequireScope(utils.ScopeLogsWrite), handler.CreateLog)
+		logRoutes.PATCH("/:id", middleware.RequireScope(utils.ScopeLogsWrite), handler.UpdateLog)
+		logRoutes.DELETE("/:id", middleware.RequireScope(utils.ScopeLogsWrite), handler.DeleteLog)
+	}
+
+	scoped.GET("/stats", middleware.RequireScope(utils.ScopeStatsRead), handler.GetStats)
+}
@@ -0,0 +1,232 @@
+// Package writing lets users keep a log of what they write each day
+package writing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/models"
+	"github.com/jinxinyu/go_backend/internal/storage"
+	"github.com/jinxinyu/go_backend/internal/textstats"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// days listed when the query gives no start
+	defaultListDays = 30
+	// longest range a list may span
+	maxListDays = 366
+)
+
+// Service manages the writing logs of a user. Every method takes the ID of
+// the authenticated user and only ever touches that user's logs.
+type Service struct {
+	logRepo  storage.WriteLogRepository
+	userRepo storage.UserRepository
+	counter  *textstats.Counter
+	timeout  time.Duration
+}
+
+func NewService(logRepo storage.WriteLogRepository, userRepo storage.UserRepository, counter *textstats.Counter) *Service {
+	return &Service{
+		logRepo:  logRepo,
+		userRepo: userRepo,
+		counter:  counter,
+		timeout:  30 * time.Second,
+	}
+}
+
+// CreateLog adds a log to the given day, today in the user's time zone by default
+func (s *Service) CreateLog(ctx context.Context, userID uuid.UUID, req *api.CreateWriteLogRequest) (*api.WriteLogResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	date, err := s.dateOrToday(ctx, userID, req.Date)
+	if err != nil {
+		return nil, err
+	}
+	writeLog := &models.WriteLog{
+		ID:      uuid.New(),
+		UserID:  userID,
+		Date:    date,
+		Content: req.Content,
+	}
+	s.applyStats(writeLog)
+	if err := s.logRepo.CreateLog(ctx, writeLog); err != nil {
+		return nil, err
+	}
+
+	log.Printf("写作记录已创建, 用户ID: %s, 日期: %s, 字数: %d", userID, date.Format(dateLayout), writeLog.WordsCount)
+	resp := toLogResponse(writeLog)
+	return &resp, nil
+}
+
+// GetLog returns one log of the user
+func (s *Service) GetLog(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*api.WriteLogResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	writeLog, err := s.getLog(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	resp := toLogResponse(writeLog)
+	return &resp, nil
+}
+
+// UpdateLog changes the date or the content of a log, the word count follows the content
+func (s *Service) UpdateLog(ctx context.Context, userID uuid.UUID, id uuid.UUID, req *api.UpdateWriteLogRequest) (*api.WriteLogResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	writeLog, err := s.getLog(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Date != nil {
+		if writeLog.Date, err = parseDate(*req.Date); err != nil {
+			return nil, err
+		}
+	}
+	if req.Content != nil {
+		writeLog.Content = *req.Content
+		s.applyStats(writeLog)
+	}
+
+	if err := s.logRepo.UpdateLog(ctx, writeLog); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	writeLog.UpdatedAt = time.Now()
+	resp := toLogResponse(writeLog)
+	return &resp, nil
+}
+
+// DeleteLog deletes a log of the user
+func (s *Service) DeleteLog(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := s.logRepo.DeleteLog(ctx, userID, id); err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return ErrLogNotFound
+		}
+		return err
+	}
+	log.Printf("写作记录已删除, 用户ID: %s, 记录ID: %s", userID, id)
+	return nil
+}
+
+// ListLogs returns the logs of the user between two days, both included. By
+// default the last 30 days up to today in the user's time zone are listed.
+func (s *Service) ListLogs(ctx context.Context, userID uuid.UUID, query *api.ListWriteLogsQuery) (*api.WriteLogListResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	to, err := s.dateOrToday(ctx, userID, query.To)
+	if err != nil {
+		return nil, err
+	}
+	from := to.AddDate(0, 0, -(defaultListDays - 1))
+	if query.From != "" {
+		if from, err = parseDate(query.From); err != nil {
+			return nil, err
+		}
+	}
+	if to.Before(from) {
+		return nil, ErrInvalidDateRange.WithDetail("from is after to")
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxListDays {
+		return nil, ErrInvalidDateRange.WithDetail("at most %d days can be listed at once", maxListDays)
+	}
+
+	logs, err := s.logRepo.GetLogByDateRange(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	resp := &api.WriteLogListResponse{
+		Logs: make([]api.WriteLogResponse, 0, len(logs)),
+		From: from.Format(dateLayout),
+		To:   to.Format(dateLayout),
+	}
+	for _, writeLog := range logs {
+		resp.Logs = append(resp.Logs, toLogResponse(writeLog))
+	}
+	return resp, nil
+}
+
+func (s *Service) getLog(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*models.WriteLog, error) {
+	writeLog, err := s.logRepo.GetLogByID(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	return writeLog, nil
+}
+
+// dateOrToday parses value, or returns today in the time zone of the user when it is empty
+func (s *Service) dateOrToday(ctx context.Context, userID uuid.UUID, value string) (time.Time, error) {
+	if value != "" {
+		return parseDate(value)
+	}
+	today, _, err := s.userToday(ctx, userID)
+	return today, err
+}
+
+// userToday returns the current day in the time zone of the user, and the zone
+func (s *Service) userToday(ctx context.Context, userID uuid.UUID) (time.Time, *time.Location, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	location, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	now := time.Now().In(location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), location, nil
+}
+
+// parseDate parses a day already validated by binding. Days are kept as
+// midnight UTC, the date column only stores the calendar day.
+func parseDate(value string) (time.Time, error) {
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidDateRange.WithDetail("invalid date %q", value)
+	}
+	return date, nil
+}
+
+// applyStats counts the content of the log into its count columns
+func (s *Service) applyStats(writeLog *models.WriteLog) {
+	writeLog.SetStats(s.counter.Count(writeLog.Content))
+}
+
+func toLogResponse(writeLog *models.WriteLog) api.WriteLogResponse {
+	return api.WriteLogResponse{
+		ID:         writeLog.ID.String(),
+		Date:       writeLog.Date.Format(dateLayout),
+		WordsCount: writeLog.WordsCount,
+		Stats: api.WriteLogStats{
+			HanCharacters: writeLog.HanCount,
+			KanaHangul:    writeLog.KanaHangulCount,
+			LatinWords:    writeLog.LatinWordCount,
+			Numbers:       writeLog.NumberCount,
+			Punctuation:   writeLog.PunctuationCount,
+			Paragraphs:    writeLog.ParagraphCount,
+			Characters:    writeLog.CharacterCount,
+		},
+		Content:   writeLog.Content,
+		CreatedAt: writeLog.CreatedAt,
+		UpdatedAt: writeLog.UpdatedAt,
+	}
+}
@@ -0,0 +1,147 @@
+package writing
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinxinyu/go_backend/internal/api"
+	"github.com/jinxinyu/go_backend/internal/storage"
+)
+
+// most buckets one stats query may return
+const maxStatsBuckets = 366
+
+// default number of buckets per period when the query gives no start
+var defaultStatsBuckets = map[string]int{
+	storage.PeriodDay:   30,
+	storage.PeriodWeek:  12,
+	storage.PeriodMonth: 12,
+}
+
+// Stats sums the logs of the user per day, ISO week or month. Log dates are
+// days of the user, so the buckets follow their time zone, and the range ends
+// today in that zone by default.
+func (s *Service) Stats(ctx context.Context, userID uuid.UUID, query *api.StatsQuery) (*api.StatsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	period := query.Period
+	if period == "" {
+		period = storage.PeriodDay
+	}
+	to, location, err := s.userToday(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if query.To != "" {
+		if to, err = parseDate(query.To); err != nil {
+			return nil, err
+		}
+	}
+	from := bucketStart(period, to)
+	from = addPeriods(period, from, -(defaultStatsBuckets[period] - 1))
+	if query.From != "" {
+		if from, err = parseDate(query.From); err != nil {
+			return nil, err
+		}
+	}
+	if to.Before(from) {
+		return nil, ErrInvalidDateRange.WithDetail("from is after to")
+	}
+	if buckets := countBuckets(period, from, to); buckets > maxStatsBuckets {
+		return nil, ErrInvalidDateRange.WithDetail("the range spans %d buckets, at most %d are allowed", buckets, maxStatsBuckets)
+	}
+
+	buckets, err := s.logRepo.Aggregate(ctx, userID, period, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &api.StatsResponse{
+		Period:   period,
+		From:     from.Format(dateLayout),
+		To:       to.Format(dateLayout),
+		TimeZone: location.String(),
+		Buckets:  make([]api.StatsBucket, 0, len(buckets)),
+	}
+	for _, bucket := range buckets {
+		start := time.Date(bucket.Start.Year(), bucket.Start.Month(), bucket.Start.Day(), 0, 0, 0, 0, time.UTC)
+		// only the days of the bucket inside the range count for its average
+		first, last := start, addPeriods(period, start, 1).AddDate(0, 0, -1)
+		if first.Before(from) {
+			first = from
+		}
+		if last.After(to) {
+			last = to
+		}
+		item := api.StatsBucket{
+			Start:         start.Format(dateLayout),
+			Days:          daysBetween(first, last),
+			ActiveDays:    int(bucket.ActiveDays),
+			Logs:          int(bucket.Logs),
+			Words:         int(bucket.Words),
+			HanCharacters: int(bucket.HanCharacters),
+			KanaHangul:    int(bucket.KanaHangul),
+			LatinWords:    int(bucket.LatinWords),
+		}
+		item.AverageWordsPerDay = average(item.Words, item.Days)
+		resp.Buckets = append(resp.Buckets, item)
+
+		resp.Totals.ActiveDays += item.ActiveDays
+		resp.Totals.Logs += item.Logs
+		resp.Totals.Words += item.Words
+		resp.Totals.HanCharacters += item.HanCharacters
+		resp.Totals.KanaHangul += item.KanaHangul
+		resp.Totals.LatinWords += item.LatinWords
+	}
+	resp.Totals.Days = daysBetween(from, to)
+	resp.Totals.AverageWordsPerDay = average(resp.Totals.Words, resp.Totals.Days)
+	resp.Totals.AverageWordsPerBucket = average(resp.Totals.Words, len(resp.Buckets))
+	return resp, nil
+}
+
+// bucketStart returns the first day of the bucket holding day
+func bucketStart(period string, day time.Time) time.Time {
+	switch period {
+	case storage.PeriodWeek:
+		// ISO weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case storage.PeriodMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// addPeriods moves start, the first day of a bucket, by n buckets
+func addPeriods(period string, start time.Time, n int) time.Time {
+	switch period {
+	case storage.PeriodWeek:
+		return start.AddDate(0, 0, 7*n)
+	case storage.PeriodMonth:
+		return start.AddDate(0, n, 0)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+func countBuckets(period string, from time.Time, to time.Time) int {
+	switch period {
+	case storage.PeriodWeek:
+		return (daysBetween(bucketStart(period, from), bucketStart(period, to))-1)/7 + 1
+	case storage.PeriodMonth:
+		return (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+	}
+	return daysBetween(from, to)
+}
+
+// daysBetween counts the days from first to last, both included
+func daysBetween(first time.Time, last time.Time) int {
+	return int(last.Sub(first).Hours()/24) + 1
+}
+
+func average(total int, count int) float64 {
+	if count <= 0 {
+		return 0
+	}
+	return float64(total) / float64(count)
+}